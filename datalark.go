@@ -14,6 +14,21 @@
 
 	All IPLD data exposed to starlark always acts as if it is "frozen", in starlark parlance.
 	This should be unsurprising, since IPLD is already oriented around immutability.
+	To change data, make an updated copy of it instead: structs have a "replace" method
+	that takes new values for some of their fields, and structs, unions, maps, and lists
+	have a "with_path" method, which sets the data at a path like "a.b.c".
+
+	Datalark values are different types than starlark's own values, and starlark only
+	treats values of the same type as equal. So although a datalark string hashes like
+	the starlark string it holds, it can't be used to look up that key in a dict:
+	`{"a": 1}[datalark.String("a")]` fails to find "a". The "unwrap" function returns the
+	plain starlark value of a scalar, which can be used as a dict key instead.
+	For the same reason, comparing a datalark value with a starlark value, as in
+	`datalark.String("abc") == "abc"` or `datalark.Int(3) == 3`, is always False;
+	compare the result of unwrap instead, as in `datalark.unwrap(s) == "abc"`.
+	Enum values don't equal the names of their members either, and unwrap doesn't
+	accept them; their "name" attribute is the member name as a starlark string,
+	as in `color.name == "Red"`.
 
 	datalark can be used on natural golang structs by combining it with the
 	go-ipld-prime/node/bindnode package.
@@ -21,14 +36,18 @@
 	(although admittedly more complicated; it's probably only worth it if you
 	also already value some of the features of IPLD Schemas).
 
-	Future objectives for this package include the ability to provide a function to starlark
-	which will accept an IPLD Schema document and a type name as parameters,
-	and will return a constructor for that type.
-	(Not yet implemented.)
+	Scripts can also get constructors for themselves, without the host having to provide them:
+	the "schema" function in the primitive constructors accepts an IPLD Schema document,
+	and returns constructors for all the types in it.
 */
 package datalark
 
 import (
+	"io"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/linking"
 	"github.com/ipld/go-ipld-prime/schema"
 	"go.starlark.net/starlark"
 
@@ -36,13 +55,22 @@ import (
 )
 
 // InjectGlobals mutates a starlark.StringDict to contain the values in the given Object.
-// It will panic if keys that aren't starlark.String are encountered, if iterators error, etc.
-func InjectGlobals(globals starlark.StringDict, obj *datalarkengine.Object) {
-	datalarkengine.InjectGlobals(globals, obj)
+// It returns an error if keys that aren't starlark.String are encountered, if lookups error, etc.
+func InjectGlobals(globals starlark.StringDict, obj *datalarkengine.Object) error {
+	return datalarkengine.InjectGlobals(globals, obj)
 }
 
 // PrimitiveConstrutors returns an Object containing constructor functions
 // for all the IPLD Data Model kinds -- strings, maps, etc -- as those names, in TitleCase.
+// It also contains a "store" function, which stores a value and returns a link to it
+// (this requires a LinkSystem; see SetLinkSystem),
+// a "codec" object, containing "encode" and "decode" functions for serializing data,
+// a "schema" function, which parses an IPLD Schema document and returns constructors for its types,
+// a "repr" function, which returns the representation view of a value
+// (which the "Repr" variant of a type's constructor turns back into a value of that type),
+// an "unwrap" function, which returns the plain starlark value for a scalar,
+// for use as a dict key or in comparisons with starlark values,
+// and a "bigint" function, which reads back an int stored by a policy set with SetBigIntPolicy.
 func PrimitiveConstructors() *datalarkengine.Object {
 	return datalarkengine.PrimitiveConstructors()
 }
@@ -53,3 +81,52 @@ func PrimitiveConstructors() *datalarkengine.Object {
 func MakeConstructors(prototypes []schema.TypedPrototype) *datalarkengine.Object {
 	return datalarkengine.MakeConstructors(prototypes)
 }
+
+// LoadSchema parses an IPLD Schema document in its DSL form, returning a TypeSystem
+// that can be given to ConstructorsFromTypeSystem.
+// The name is used in any parse errors, and is typically the name of the file.
+func LoadSchema(name string, r io.Reader) (*schema.TypeSystem, error) {
+	return ipld.LoadSchema(name, r)
+}
+
+// ConstructorsFromTypeSystem returns an Object containing constructor functions for the types
+// in a TypeSystem, using the names of the types as the keys.
+// The options can limit which types get constructors (by default, all of them do),
+// optionally along with every type that those types refer to, so scripts see only the types they need.
+// They can also nest the constructors inside a namespace (which is handy with InjectGlobals),
+// and bind types to your own Go types, using the go-ipld-prime/node/bindnode package.
+// It returns an error if the options name types that aren't in the TypeSystem,
+// or if a Go type doesn't match the type it's bound to.
+func ConstructorsFromTypeSystem(ts *schema.TypeSystem, opts datalarkengine.ConstructorOptions) (*datalarkengine.Object, error) {
+	return datalarkengine.ConstructorsFromTypeSystem(ts, opts)
+}
+
+// SetLinkSystem binds a LinkSystem to a starlark.Thread.
+// Scripts running on that thread can then call the "load_node" method on link values,
+// which returns the data the link points to (optionally typed, if given a constructor as a parameter).
+// Without a LinkSystem, links can still be inspected, but not loaded.
+func SetLinkSystem(thread *starlark.Thread, lsys *linking.LinkSystem) {
+	datalarkengine.SetLinkSystem(thread, lsys)
+}
+
+// SetBigIntPolicy sets what happens to starlark ints that are too big for the IPLD
+// data model, whose ints are 64 bits, when scripts running on a starlark.Thread construct data.
+// By default they are rejected, with an error naming where in the data they were.
+// The other policies store them as decimal strings, or as big-endian bytes,
+// wherever the schema allows that kind; scripts can read either form back
+// as a starlark int with `datalark.bigint(v)`.
+// The policy applies to the arguments of constructors, to data given to "store" and "codec.encode",
+// and to values added by methods of maps and lists, such as "append" and "update".
+// Values assigned by `x[k] = v`, or by "with_path", must already fit,
+// because starlark doesn't tell those operations which thread they are running on.
+func SetBigIntPolicy(thread *starlark.Thread, policy datalarkengine.BigIntPolicy) {
+	datalarkengine.SetBigIntPolicy(thread, policy)
+}
+
+// SetLinkPrototype sets the LinkPrototype used when scripts running on a starlark.Thread store data.
+// The "store" function also accepts "codec", "hasher", and "version" parameters,
+// which override the corresponding parts of this LinkPrototype (if it's a cidlink.LinkPrototype).
+// If no LinkPrototype is set, CIDv1 with dag-cbor and sha2-256 is used.
+func SetLinkPrototype(thread *starlark.Thread, lp datamodel.LinkPrototype) {
+	datalarkengine.SetLinkPrototype(thread, lp)
+}
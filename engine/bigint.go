@@ -0,0 +1,108 @@
+package datalarkengine
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"go.starlark.net/starlark"
+)
+
+// BigIntPolicy decides what happens to starlark ints that are too big for the
+// IPLD data model, whose ints are 64 bits
+type BigIntPolicy int32
+
+const (
+	// BigIntError rejects big ints, with an error naming where they were in the data
+	BigIntError BigIntPolicy = iota
+	// BigIntAsString stores big ints as decimal strings, wherever the schema allows a string
+	BigIntAsString
+	// BigIntAsBytes stores big ints as big-endian unsigned bytes, wherever the schema
+	// allows bytes. Negative big ints are still rejected
+	BigIntAsBytes
+)
+
+// threadLocalBigIntPolicy is the key under which a BigIntPolicy is stored in a starlark.Thread
+const threadLocalBigIntPolicy = "datalark.BigIntPolicy"
+
+// See docs on datalark.SetBigIntPolicy.
+//
+// The policy is read when a constructor is called, and carried along in its
+// ArgSeq to wherever the ints are assembled. Methods of maps and lists which
+// add values, such as append and update, read it the same way. Assignments by
+// `x[k] = v` always use BigIntError, since starlark doesn't give the thread to
+// SetKey and SetIndex, and so does with_path.
+func SetBigIntPolicy(thread *starlark.Thread, policy BigIntPolicy) {
+	thread.SetLocal(threadLocalBigIntPolicy, policy)
+}
+
+func bigIntPolicyFor(thread *starlark.Thread) BigIntPolicy {
+	if thread != nil {
+		if policy, ok := thread.Local(threadLocalBigIntPolicy).(BigIntPolicy); ok {
+			return policy
+		}
+	}
+	return BigIntError
+}
+
+// bigIntError is the error for an int that can't be assigned. Where it was
+// in the data is added by atPath, like any other error from construction
+type bigIntError struct {
+	val starlark.Int
+}
+
+func (e *bigIntError) Error() string {
+	return fmt.Sprintf("int %s is too big, IPLD ints must fit in 64 bits", e.val)
+}
+
+func isBigIntError(err error) bool {
+	var bigErr *bigIntError
+	return errors.As(err, &bigErr)
+}
+
+// assembleBigInt assigns an int that doesn't fit in 64 bits, according to the policy
+func assembleBigInt(na datamodel.NodeAssembler, n starlark.Int, policy BigIntPolicy) error {
+	switch policy {
+	case BigIntAsString:
+		if err := na.AssignString(n.String()); err == nil {
+			return nil
+		}
+	case BigIntAsBytes:
+		if n.Sign() >= 0 {
+			if err := na.AssignBytes(n.BigInt().Bytes()); err == nil {
+				return nil
+			}
+		}
+	}
+	return &bigIntError{val: n}
+}
+
+// bigIntFunc reads an int back from the form a BigIntPolicy stored it in,
+// which is a decimal string, or big-endian unsigned bytes. Ints are returned
+// as they are, so scripts don't need to know which form a value is in
+func bigIntFunc(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var val starlark.Value
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &val); err != nil {
+		return starlark.None, err
+	}
+	if v, ok := asBasic(val); ok {
+		var err error
+		if val, err = v.toStarlark(); err != nil {
+			return starlark.None, fmt.Errorf("%s: %w", b.Name(), err)
+		}
+	}
+	switch it := val.(type) {
+	case starlark.Int:
+		return it, nil
+	case starlark.String:
+		n, ok := new(big.Int).SetString(string(it), 10)
+		if !ok {
+			return starlark.None, fmt.Errorf("%s: %q is not a decimal int", b.Name(), string(it))
+		}
+		return starlark.MakeBigInt(n), nil
+	case starlark.Bytes:
+		return starlark.MakeBigInt(new(big.Int).SetBytes([]byte(it))), nil
+	}
+	return starlark.None, fmt.Errorf("%s: cannot read an int from %s", b.Name(), val.Type())
+}
@@ -0,0 +1,193 @@
+package datalarkengine
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"go.starlark.net/starlark"
+
+	"github.com/ipld/go-datalark/testutil"
+)
+
+var bigIntSchema = `
+	type Transfer struct {
+		to String
+		amount Amount
+		fees [Amount]
+	}
+	type Amount string
+	type RawTransfer struct {
+		amount RawAmount
+	}
+	type RawAmount bytes
+	type Tally struct {
+		count Int
+	}
+	type Ledger {String:Int}
+	type Counts [Int]
+`
+
+func TestBigIntRejectedByDefault(t *testing.T) {
+	defines := mustParseSchemaDefines(t, bigIntSchema)
+
+	_, err := runScript(defines, "mytypes", `
+		mytypes.Transfer(to="a", amount="1", fees=["1", 100000000000000000000])
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `Transfer.fees.1: int 100000000000000000000 is too big, IPLD ints must fit in 64 bits`)
+
+	_, err = runScript(defines, "mytypes", `
+		datalark.Map(a={"b": [1, -100000000000000000000]})
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `Map.a.b.1: int -100000000000000000000 is too big, IPLD ints must fit in 64 bits`)
+
+	_, err = runScript(defines, "mytypes", `
+		mytypes.Ledger(a=1, b=100000000000000000000)
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `Ledger.b: int 100000000000000000000 is too big, IPLD ints must fit in 64 bits`)
+
+	_, err = runScript(defines, "mytypes", `
+		mytypes.Counts(1, 100000000000000000000)
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `Counts.1: int 100000000000000000000 is too big, IPLD ints must fit in 64 bits`)
+
+	_, err = runScript(defines, "mytypes", `
+		datalark.Int(100000000000000000000)
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `int 100000000000000000000 is too big, IPLD ints must fit in 64 bits`)
+
+	_, err = runScript(defines, "mytypes", `
+		datalark.List(_=[1, 2]).append(100000000000000000000)
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `.*int 100000000000000000000 is too big, IPLD ints must fit in 64 bits`)
+}
+
+func TestBigIntAsString(t *testing.T) {
+	defines := mustParseSchemaDefines(t, bigIntSchema)
+
+	output, err := runScript(defines, "mytypes", `
+		t = mytypes.Transfer(to="a", amount=100000000000000000000, fees=[123456789012345678901234567890])
+		print(t.amount)
+		print(datalark.bigint(t.amount) + 1)
+		print(datalark.bigint(t.fees[0]) == 123456789012345678901234567890)
+		print(t.fees[0])
+		print(datalark.Map(a=1, b=-100000000000000000000))
+	`, withBigIntPolicy(BigIntAsString))
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, output, qt.Equals, testutil.Dedent(`
+		string<Amount>{"100000000000000000000"}
+		100000000000000000001
+		True
+		string<Amount>{"123456789012345678901234567890"}
+		map{
+			string{"a"}: int{1}
+			string{"b"}: string{"-100000000000000000000"}
+		}
+	`))
+
+	// ints that fit are still ints, and schemas which don't allow strings still reject big ints
+	_, err = runScript(defines, "mytypes", `
+		mytypes.Tally(count=100000000000000000000)
+	`, withBigIntPolicy(BigIntAsString))
+	qt.Assert(t, err, qt.ErrorMatches, `Tally.count: int 100000000000000000000 is too big, IPLD ints must fit in 64 bits`)
+
+	// methods which add to maps and lists use the thread's policy
+	output, err = runScript(defines, "mytypes", `
+		ledger = datalark.Map(a=1)
+		ledger.update({"b": 1000000000000000000000000000000})
+		ledger.setdefault("c", -1000000000000000000000000000000)
+		counts = datalark.List(_=[1])
+		counts.append(1000000000000000000000000000000)
+		counts.insert(0, 100000000000000000000)
+		counts.extend([1000000000000000000000])
+		print(datalark.bigint(ledger["b"]), datalark.bigint(ledger["c"]))
+		print([datalark.bigint(c) for c in counts])
+	`, withBigIntPolicy(BigIntAsString))
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, output, qt.Equals, testutil.Dedent(`
+		1000000000000000000000000000000 -1000000000000000000000000000000
+		[100000000000000000000, 1, 1000000000000000000000000000000, 1000000000000000000000]
+	`))
+
+	// but assignments by index don't get the thread, so they still reject big ints
+	_, err = runScript(defines, "mytypes", `
+		m = datalark.Map(a=1)
+		m["b"] = -100000000000000000000
+	`, withBigIntPolicy(BigIntAsString))
+	qt.Assert(t, err, qt.ErrorMatches, `int -100000000000000000000 is too big, IPLD ints must fit in 64 bits`)
+
+	_, err = runScript(defines, "mytypes", `
+		counts = datalark.List(_=[1])
+		counts[0] = 1000000000000000000000000000000
+	`, withBigIntPolicy(BigIntAsString))
+	qt.Assert(t, err, qt.ErrorMatches, `int 1000000000000000000000000000000 is too big, IPLD ints must fit in 64 bits`)
+}
+
+func TestBigIntAsBytes(t *testing.T) {
+	defines := mustParseSchemaDefines(t, bigIntSchema)
+
+	output, err := runScript(defines, "mytypes", `
+		r = mytypes.RawTransfer(amount=100000000000000000000)
+		print(r.amount)
+		print(datalark.bigint(r.amount) + 1)
+		counts = datalark.List(_=[])
+		counts.append(1000000000000000000000000000000)
+		print(datalark.bigint(counts[0]))
+	`, withBigIntPolicy(BigIntAsBytes))
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, output, qt.Equals, testutil.Dedent(`
+		bytes<RawAmount>{056bc75e2d63100000}
+		100000000000000000001
+		1000000000000000000000000000000
+	`))
+
+	// negative ints have no bytes form
+	_, err = runScript(defines, "mytypes", `
+		mytypes.RawTransfer(amount=-100000000000000000000)
+	`, withBigIntPolicy(BigIntAsBytes))
+	qt.Assert(t, err, qt.ErrorMatches, `RawTransfer.amount: int -100000000000000000000 is too big, IPLD ints must fit in 64 bits`)
+}
+
+func TestBigIntPolicyIsPerThread(t *testing.T) {
+	defines := mustParseSchemaDefines(t, bigIntSchema)
+
+	_, err := runScript(defines, "mytypes", `
+		mytypes.Transfer(to="a", amount=100000000000000000000, fees=[])
+	`, withBigIntPolicy(BigIntAsString))
+	qt.Assert(t, err, qt.IsNil)
+
+	// another thread, without a policy, still rejects them
+	_, err = runScript(defines, "mytypes", `
+		mytypes.Transfer(to="a", amount=100000000000000000000, fees=[])
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `Transfer.amount: int 100000000000000000000 is too big, IPLD ints must fit in 64 bits`)
+}
+
+func TestStarToHostInt(t *testing.T) {
+	val, err := starToHost(starlark.MakeInt(7))
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, val.String(), qt.Equals, "int{7}")
+}
+
+func TestBigIntFunc(t *testing.T) {
+	mustParseSchemaRunScriptAssertOutput(t, "", "", `
+		print(datalark.bigint("-123456789012345678901234567890"))
+		print(datalark.bigint(b"\x01\x00"))
+		print(datalark.bigint(datalark.Int(7)))
+		print(datalark.bigint(7))
+	`, `
+		-123456789012345678901234567890
+		256
+		7
+		7
+	`)
+
+	_, err := runScript(nil, "", `
+		datalark.bigint("12a")
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `bigint: "12a" is not a decimal int`)
+
+	_, err = runScript(nil, "", `
+		datalark.bigint(datalark.List(_=[1]))
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `bigint: cannot read an int from datalark.List`)
+}
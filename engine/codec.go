@@ -0,0 +1,135 @@
+package datalarkengine
+
+import (
+	"bytes"
+	"fmt"
+
+	_ "github.com/ipld/go-ipld-prime/codec/cbor" // the codecs below all register themselves with the multicodec registry
+	_ "github.com/ipld/go-ipld-prime/codec/dagcbor"
+	_ "github.com/ipld/go-ipld-prime/codec/dagjson"
+	_ "github.com/ipld/go-ipld-prime/codec/json"
+	_ "github.com/ipld/go-ipld-prime/codec/raw"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/multicodec"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/ipld/go-ipld-prime/schema"
+	"go.starlark.net/starlark"
+)
+
+// multicodecNames maps the names of the codecs that go-ipld-prime ships with to their multicodec indicators.
+var multicodecNames = map[string]uint64{
+	"raw":      0x55,
+	"dag-pb":   0x70,
+	"dag-cbor": 0x71,
+	"dag-json": 0x0129,
+	"cbor":     0x51,
+	"json":     0x0200,
+}
+
+// textualCodecs are the codecs whose output is encoded to a string rather than to bytes.
+var textualCodecs = map[uint64]bool{
+	0x0129: true,
+	0x0200: true,
+}
+
+// CodecFunctions returns an Object containing the "encode" and "decode" functions,
+// which serialize data using any codec in the multicodec registry
+func CodecFunctions() *Object {
+	obj := NewObject(2)
+	obj.SetKey(starlark.String("encode"), starlark.NewBuiltin("encode", guardBuiltin(codecEncode)))
+	obj.SetKey(starlark.String("decode"), starlark.NewBuiltin("decode", guardBuiltin(codecDecode)))
+	obj.Freeze()
+	return obj
+}
+
+// codecEncode serializes a value, returning a string for textual codecs (like dag-json), or else bytes.
+// Typed data is encoded in its representation form.
+func codecEncode(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var starVal, starCodec starlark.Value
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "value", &starVal, "codec", &starCodec); err != nil {
+		return starlark.None, err
+	}
+	code, err := lookupCode(starCodec, multicodecNames, "codec")
+	if err != nil {
+		return starlark.None, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+	encoder, err := multicodec.LookupEncoder(code)
+	if err != nil {
+		return starlark.None, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+
+	node, err := nodeForStoring(starVal, bigIntPolicyFor(thread))
+	if err != nil {
+		return starlark.None, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+	if tn, ok := node.(schema.TypedNode); ok {
+		node = tn.Representation()
+	}
+
+	var buf bytes.Buffer
+	if err := encoder(node, &buf); err != nil {
+		return starlark.None, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+	if textualCodecs[code] {
+		return starlark.String(buf.String()), nil
+	}
+	return starlark.Bytes(buf.String()), nil
+}
+
+// codecDecode deserializes bytes (or a string) into a Value.
+// If a Prototype is given, the data is decoded as that type, and must match it.
+func codecDecode(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var starData, starCodec, starProto starlark.Value
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "data", &starData, "codec", &starCodec, "prototype?", &starProto); err != nil {
+		return starlark.None, err
+	}
+	var data string
+	switch it := starData.(type) {
+	case starlark.Bytes:
+		data = string(it)
+	case starlark.String:
+		data = string(it)
+	case Value:
+		n := it.Node()
+		var err error
+		if n.Kind() == datamodel.Kind_Bytes {
+			var raw []byte
+			raw, err = n.AsBytes()
+			data = string(raw)
+		} else {
+			data, err = n.AsString()
+		}
+		if err != nil {
+			return starlark.None, fmt.Errorf("%s: data must be bytes or a string, got %s", b.Name(), it.Type())
+		}
+	default:
+		return starlark.None, fmt.Errorf("%s: data must be bytes or a string, got %s", b.Name(), starData.Type())
+	}
+	code, err := lookupCode(starCodec, multicodecNames, "codec")
+	if err != nil {
+		return starlark.None, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+	decoder, err := multicodec.LookupDecoder(code)
+	if err != nil {
+		return starlark.None, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+
+	var np datamodel.NodePrototype = basicnode.Prototype.Any
+	switch it := starProto.(type) {
+	case nil, starlark.NoneType:
+		// untyped
+	case *Prototype:
+		np = it.np
+		if tp, ok := np.(schema.TypedPrototype); ok {
+			np = tp.Representation()
+		}
+	default:
+		return starlark.None, fmt.Errorf("%s: prototype must be a constructor, got %s", b.Name(), starProto.Type())
+	}
+
+	nb := np.NewBuilder()
+	if err := decoder(nb, bytes.NewReader([]byte(data))); err != nil {
+		return starlark.None, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+	return ToValue(nb.Build())
+}
@@ -0,0 +1,69 @@
+package datalarkengine
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestCodecRoundtrip(t *testing.T) {
+	mustParseSchemaRunScriptAssertOutput(t,
+		`
+		type FooBar struct {
+			foo String
+			bar Int
+		} representation tuple
+	`,
+		"mytypes",
+		`
+		data = datalark.codec.encode(mytypes.FooBar(foo="x", bar=2), "dag-cbor")
+		print(type(data))
+		print(datalark.codec.decode(data, "dag-cbor", prototype=mytypes.FooBar))
+		print(datalark.codec.decode(datalark.Bytes(data), 0x71))
+	`, `
+		bytes
+		struct<FooBar>{
+			foo: string<String>{"x"}
+			bar: int<Int>{2}
+		}
+		list{
+			0: string{"x"}
+			1: int{2}
+		}
+	`)
+}
+
+func TestCodecErrors(t *testing.T) {
+	defines := mustParseSchemaDefines(t, `
+		type FooBar struct {
+			foo String
+			bar Int
+		}
+	`)
+
+	_, err := runScript(defines, "mytypes", `
+		datalark.codec.encode("x", "bogus")
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `encode: unknown codec "bogus"`)
+
+	_, err = runScript(defines, "mytypes", `
+		datalark.codec.encode("x", 0x99999)
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `encode: no encoder registered .*`)
+
+	_, err = runScript(defines, "mytypes", `
+		datalark.codec.decode(3, "dag-json")
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `decode: data must be bytes or a string, got int`)
+
+	// the data doesn't match the schema
+	_, err = runScript(defines, "mytypes", `
+		datalark.codec.decode('{"foo":"x","bar":"y"}', "dag-json", prototype=mytypes.FooBar)
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `decode: .*`)
+
+	_, err = runScript(defines, "mytypes", `
+		datalark.codec.decode('{"foo":', "dag-json")
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `decode: .*`)
+}
@@ -2,6 +2,8 @@ package datalarkengine
 
 import (
 	"testing"
+
+	qt "github.com/frankban/quicktest"
 )
 
 // Test map construction using restructuring
@@ -343,3 +345,229 @@ func TestLenBuiltinForListAndMap(t *testing.T) {
 `,
 	)
 }
+
+func TestCompareContainers(t *testing.T) {
+	mustParseSchemaRunScriptAssertOutput(t, `
+		type FooBar struct {
+			foo String
+			bar Int
+		}
+		type StrOrInt union {
+			| String "s"
+			| Int "i"
+		} representation keyed
+	`, "mytypes", `
+		print(mytypes.FooBar(foo="a", bar=1) == mytypes.FooBar(foo="a", bar=1))
+		print(mytypes.FooBar(foo="a", bar=1) == mytypes.FooBar(foo="a", bar=2))
+		print(mytypes.StrOrInt(s="x") == mytypes.StrOrInt(s="x"))
+		print(mytypes.StrOrInt(s="x") != mytypes.StrOrInt(i=1))
+		print(datalark.Map(a=1) == datalark.Map(a=1))
+		print(datalark.Map(a=1) == datalark.Map(a=2))
+		print(datalark.List(_=[1, 2]) == datalark.List(_=[1, 2]))
+		print(datalark.List(_=[1, 2]) < datalark.List(_=[1, 3]))
+		print(datalark.List(_=[1]) < datalark.List(_=[1, 0]))
+	`, `
+		True
+		False
+		True
+		True
+		True
+		False
+		True
+		True
+		True
+	`)
+
+	_, err := runScript(nil, "", `
+		datalark.Map(a=1) < datalark.Map(a=1)
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `datalark.Map < datalark.Map not implemented`)
+}
+
+var reprViewSchema = `
+	type Foo struct {
+		a String
+		b String
+	} representation stringjoin {
+		join ":"
+	}
+	type Bar struct {
+		n Int
+		items [Foo]
+	}
+	type Thing union {
+		| Foo "foo"
+		| Bar "bar"
+	} representation keyed
+	type Color enum {
+		| Red ("r")
+		| Green ("g")
+	} representation string
+	type Names [Foo]
+`
+
+func TestReprView(t *testing.T) {
+	mustParseSchemaRunScriptAssertOutput(t, reprViewSchema, "mytypes", `
+		print(datalark.repr(mytypes.Thing(Bar=mytypes.Bar(n=1, items=[mytypes.Foo("x:y")]))))
+		print(datalark.repr(mytypes.Color("Red")))
+		print(datalark.repr(mytypes.Names("a:b")))
+		print(datalark.repr(datalark.Int(1)), datalark.repr(3))
+	`, `
+		map{
+			string{"bar"}: map{
+				string{"n"}: int{1}
+				string{"items"}: list{
+					0: string{"x:y"}
+				}
+			}
+		}
+		string{"r"}
+		list{
+			0: string{"a:b"}
+		}
+		int{1} 3
+	`)
+}
+
+func TestReprViewRoundTrip(t *testing.T) {
+	mustParseSchemaRunScriptAssertOutput(t, reprViewSchema, "mytypes", `
+		values = [
+			(mytypes.Thing, mytypes.Thing(Bar=mytypes.Bar(n=1, items=[mytypes.Foo("x:y")]))),
+			(mytypes.Thing, mytypes.Thing(Foo=mytypes.Foo("p:q"))),
+			(mytypes.Bar, mytypes.Bar(n=2, items=[])),
+			(mytypes.Names, mytypes.Names("a:b", "c:d")),
+			(mytypes.Color, mytypes.Color("Green")),
+		]
+		print([proto.Repr(datalark.repr(v)) == v for proto, v in values])
+		print(mytypes.Thing.Repr({"foo": "a:b"}).Foo.b)
+	`, `
+		[True, True, True, True, True]
+		string<String>{"b"}
+	`)
+}
+
+func TestReprViewErrors(t *testing.T) {
+	defines := mustParseSchemaDefines(t, reprViewSchema)
+
+	_, err := runScript(defines, "mytypes", `datalark.repr()`)
+	qt.Assert(t, err, qt.ErrorMatches, `repr: got 0 arguments, want 1`)
+
+	// the error from the representation is reported, rather than from the
+	// other ways that the value might have been constructed
+	_, err = runScript(defines, "mytypes", `mytypes.Thing.Repr({"nope": "a:b"})`)
+	qt.Assert(t, err, qt.ErrorMatches, `Thing\.nope: .*missing member nope in Thing`)
+
+	_, err = runScript(defines, "mytypes", `mytypes.Names.Repr(["a:b", 1])`)
+	qt.Assert(t, err, qt.ErrorMatches, `Names\.1: .*`)
+}
+
+var withPathSchema = `
+	type Doc struct {
+		title String
+		owner Person
+		tags [String]
+		scores {String:Int}
+		byYear {Int:String}
+		body Body
+	}
+	type Person struct {
+		name String
+		email optional String
+	}
+	type Body union {
+		| String "text"
+		| Sections "sections"
+	} representation keyed
+	type Sections [Section]
+	type Section struct {
+		heading String
+		words Int
+	}
+`
+
+// withPathDoc is a script for a value to update, indented like the scripts it starts
+var withPathDoc = `
+		doc = mytypes.Doc(
+			title="Notes",
+			owner=mytypes.Person(name="Ann"),
+			tags=["a", "b"],
+			scores={"x": 1},
+			byYear={2020: "old"},
+			body=mytypes.Body(Sections=[{"heading": "Intro", "words": 10}, {"heading": "End", "words": 5}]),
+		)`
+
+func TestWithPath(t *testing.T) {
+	mustParseSchemaRunScriptAssertOutput(t, withPathSchema, "mytypes", withPathDoc+`
+		print(doc.with_path("owner.name", "Bob").owner.name, doc.owner.name)
+		print(doc.with_path("owner.email", "b@example.com").owner.email)
+		print(doc.with_path("tags.1", "c").tags, doc.with_path("tags.-2", "z").tags[0])
+		print(doc.with_path("scores.x", 2).scores, doc.with_path("scores.y", 3).scores["y"])
+		print(doc.with_path("byYear.2020", "new").byYear[2020])
+		print(doc.with_path("body.Sections.1.words", 50).body.Sections[1].words)
+		print(doc.with_path("body.String", "plain").body.member)
+		print(doc.with_path(["scores", "a.b"], 4).scores["a.b"], doc.with_path(("tags", -1), "d").tags[1])
+		print(doc.body.Sections[1].words, doc.tags[1], len(doc.scores))
+	`, `
+		string<String>{"Bob"} string<String>{"Ann"}
+		string<String>{"b@example.com"}
+		list<List__String>{
+			0: string<String>{"a"}
+			1: string<String>{"c"}
+		} string<String>{"z"}
+		map<Map__String__Int>{
+			string<String>{"x"}: int<Int>{2}
+		} int<Int>{3}
+		string<String>{"new"}
+		int<Int>{50}
+		String
+		int<Int>{4} string<String>{"d"}
+		int<Int>{5} string<String>{"b"} 1
+	`)
+}
+
+func TestWithPathUntyped(t *testing.T) {
+	mustParseSchemaRunScriptAssertOutput(t, "", "", `
+		m = datalark.Map(a={"b": [1, 2]})
+		print(m.with_path("a.b.0", "one"))
+		print(datalark.List(_=[{"k": 1}]).with_path("0.k", 2)[0]["k"])
+		print(m["a"]["b"][0])
+	`, `
+		map{
+			string{"a"}: map{
+				string{"b"}: list{
+					0: string{"one"}
+					1: int{2}
+				}
+			}
+		}
+		int{2}
+		int{1}
+	`)
+}
+
+func TestWithPathErrors(t *testing.T) {
+	defines := mustParseSchemaDefines(t, withPathSchema)
+	errorCases := []struct {
+		script string
+		expect string
+	}{
+		{`doc.with_path("owner.nmae", "x")`, `with_path: Doc\.owner: "nmae" is not a field of struct Person \(did you mean "name"\?\)`},
+		{`doc.with_path("owner.name", 1)`, `with_path: Doc\.owner\.name: .*`},
+		{`doc.with_path("owner.email.x", 1)`, `with_path: Doc\.owner\.email: cannot set "x" inside of an absent field`},
+		{`doc.with_path("title.x", 1)`, `with_path: Doc\.title: cannot set "x" inside of .*`},
+		{`doc.with_path("tags.5", "c")`, `with_path: Doc\.tags\.5: index out of range, index = 5, len = 2`},
+		{`doc.with_path(["tags", 5], "c")`, `with_path: Doc\.tags\.5: index out of range, index = 5, len = 2`},
+		{`doc.with_path("tags.one", "c")`, `with_path: Doc\.tags: "one" is not an index of .*`},
+		{`doc.with_path("scores.y.z", 3)`, `with_path: Doc\.scores: key "y" is not in .*`},
+		{`doc.with_path("body.String.x", "a")`, `with_path: Doc\.body: union Body holds a Sections, not a String`},
+		{`doc.with_path("body.Nope", "a")`, `with_path: Doc\.body: "Nope" is not a member of union Body \(members: String, Sections\)`},
+		{`doc.with_path("owner..name", "x")`, `with_path: invalid path "owner..name", it has an empty part`},
+		{`doc.with_path(1, "x")`, `with_path: path must be a string, or a list of its parts: expected a string, got int`},
+		{`doc.with_path([], "x")`, `with_path: invalid path, it has no parts`},
+		{`doc.with_path(["tags", 1.5], "x")`, `with_path: invalid path, part 1 must be a string or int: expected a string, got float`},
+	}
+	for _, c := range errorCases {
+		_, err := runScript(defines, "mytypes", withPathDoc+"\n\t\t"+c.script)
+		qt.Assert(t, err, qt.ErrorMatches, c.expect, qt.Commentf("script: %s", c.script))
+	}
+}
@@ -10,20 +10,15 @@ package datalarkengine
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
 
 	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
 	"github.com/ipld/go-ipld-prime/schema"
 	"go.starlark.net/starlark"
 )
 
-func nodeToHost(n datamodel.Node) Value {
-	val, err := ToValue(n)
-	if err != nil {
-		panic(err)
-	}
-	return val
-}
-
 func ToValue(n datamodel.Node) (Value, error) {
 	if nt, ok := n.(schema.TypedNode); ok {
 		switch nt.Type().TypeKind() {
@@ -32,32 +27,30 @@ func ToValue(n datamodel.Node) (Value, error) {
 		case schema.TypeKind_Union:
 			return newUnionValue(n), nil
 		case schema.TypeKind_Enum:
-			panic("IMPLEMENT ME!")
+			return newEnumValue(n), nil
 		}
 	}
 	switch n.Kind() {
 	case datamodel.Kind_Map:
-		return newMapValue(n), nil
+		return newMapValue(n)
 	case datamodel.Kind_List:
-		return newListValue(n), nil
+		return newListValue(n)
 	case datamodel.Kind_Null:
-		return newBasicValue(n, datamodel.Kind_Null), nil
+		return newBasicValue(n), nil
 	case datamodel.Kind_Bool:
-		return newBasicValue(n, datamodel.Kind_Bool), nil
+		return newBasicValue(n), nil
 	case datamodel.Kind_Int:
-		return newBasicValue(n, datamodel.Kind_Int), nil
+		return newBasicValue(n), nil
 	case datamodel.Kind_Float:
-		return newBasicValue(n, datamodel.Kind_Float), nil
+		return newBasicValue(n), nil
 	case datamodel.Kind_String:
-		return newBasicValue(n, datamodel.Kind_String), nil
+		return newBasicValue(n), nil
 	case datamodel.Kind_Bytes:
-		return newBasicValue(n, datamodel.Kind_Bytes), nil
+		return newBasicValue(n), nil
 	case datamodel.Kind_Link:
-		panic("IMPLEMENT ME!")
-	case datamodel.Kind_Invalid:
-		panic("invalid!")
+		return newLinkValue(n), nil
 	default:
-		panic("unreachable")
+		return nil, fmt.Errorf("cannot convert node of kind %s to a datalark value", n.Kind())
 	}
 }
 
@@ -75,7 +68,9 @@ func ToValue(n datamodel.Node) (Value, error) {
 // However, there is no support for primitives unless they're one of the concrete types from the starlark package;
 // starlark doesn't have a concept of a data model where you can ask what "kind" something is,
 // so if it's not *literally* one of the concrete types that we can match on, well, we're outta luck.
-func assembleFrom(na datamodel.NodeAssembler, starVal starlark.Value) error {
+//
+// Ints that don't fit in 64 bits are assigned according to the given policy.
+func assembleFrom(na datamodel.NodeAssembler, starVal starlark.Value, bigInts BigIntPolicy) error {
 	// if input value is already a hosted datalark Value, use its Node
 	if hostVal, ok := starVal.(Value); ok {
 		return na.AssignNode(hostVal.Node())
@@ -83,12 +78,14 @@ func assembleFrom(na datamodel.NodeAssembler, starVal starlark.Value) error {
 
 	// try any of the starlark primitives we can recognize
 	switch starObj := starVal.(type) {
+	case starlark.NoneType:
+		return na.AssignNull()
 	case starlark.Bool:
 		return na.AssignBool(bool(starObj))
 	case starlark.Int:
 		i, ok := starObj.Int64()
 		if !ok {
-			return fmt.Errorf("could not convert %v to int64", starVal)
+			return assembleBigInt(na, starObj, bigInts)
 		}
 		return na.AssignInt(i)
 	case starlark.Float:
@@ -107,19 +104,16 @@ func assembleFrom(na datamodel.NodeAssembler, starVal starlark.Value) error {
 		if err != nil {
 			return err
 		}
-		starIter := starObj.Iterate()
-		defer starIter.Done()
-		var skey starlark.Value
-		for starIter.Next(&skey) {
-			if err := assembleFrom(ma.AssembleKey(), skey); err != nil {
+		for _, skey := range mappingKeys(starObj) {
+			if err := assembleFrom(ma.AssembleKey(), skey, bigInts); err != nil {
 				return err
 			}
 			sval, _, err := starObj.Get(skey)
 			if err != nil {
 				return err
 			}
-			if err := assembleFrom(ma.AssembleValue(), sval); err != nil {
-				return err
+			if err := assembleFrom(ma.AssembleValue(), sval, bigInts); err != nil {
+				return atPath(err, asString(skey))
 			}
 		}
 		return ma.Finish()
@@ -136,9 +130,9 @@ func assembleFrom(na datamodel.NodeAssembler, starVal starlark.Value) error {
 		starIter := starObj.Iterate()
 		defer starIter.Done()
 		var sval starlark.Value
-		for starIter.Next(&sval) {
-			if err := assembleFrom(la.AssembleValue(), sval); err != nil {
-				return err
+		for i := 0; starIter.Next(&sval); i++ {
+			if err := assembleFrom(la.AssembleValue(), sval, bigInts); err != nil {
+				return atPath(err, strconv.Itoa(i))
 			}
 		}
 		return la.Finish()
@@ -147,6 +141,26 @@ func assembleFrom(na datamodel.NodeAssembler, starVal starlark.Value) error {
 	return fmt.Errorf("could not coerce %v of type %q into ipld datamodel", starVal, starVal.Type())
 }
 
+// mappingKeys returns the keys of a mapping in a deterministic order. Starlark
+// dicts keep their insertion order, the same as in starlark itself, while the
+// keys of any other mapping, which may not have an order at all, are sorted
+func mappingKeys(m starlark.IterableMapping) []starlark.Value {
+	if dict, ok := m.(*starlark.Dict); ok {
+		return dict.Keys()
+	}
+	var keys []starlark.Value
+	iter := m.Iterate()
+	defer iter.Done()
+	var skey starlark.Value
+	for iter.Next(&skey) {
+		keys = append(keys, skey)
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+	return keys
+}
+
 // convert a generic starlark.Value into a datalark.Value
 func starToHost(val starlark.Value) (Value, error) {
 	switch it := val.(type) {
@@ -161,7 +175,7 @@ func starToHost(val starlark.Value) (Value, error) {
 	case starlark.Int:
 		n, ok := it.Int64()
 		if !ok {
-			return nil, fmt.Errorf("int64 out or range, could not convert: %v", val)
+			return nil, &bigIntError{val: it}
 		}
 		return NewInt(n), nil
 	case starlark.Float:
@@ -173,10 +187,24 @@ func starToHost(val starlark.Value) (Value, error) {
 		return NewList(it)
 	case starlark.Bytes:
 		return NewBytes([]byte(string(it))), nil
-	case *starlark.Dict:
-		panic("TODO(dustmop): implement starToHost for dict")
+	case starlark.IterableMapping:
+		// dicts become untyped maps, so their keys must be strings
+		return composeHost(basicnode.Prototype.Map, val)
+	case starlark.Iterable:
+		// tuples and sets become untyped lists, in the order they iterate
+		return composeHost(basicnode.Prototype.List, val)
 	default:
-		// Tuple, Set, Function, Builtin
-		panic(fmt.Sprintf("unsupported type for starToHost: %T", val))
+		// Function, Builtin
+		return nil, fmt.Errorf("cannot convert %s to a datalark value", val.Type())
+	}
+}
+
+// composeHost builds a composite starlark value into a node of the given
+// prototype, converting everything inside of it as well
+func composeHost(np datamodel.NodePrototype, val starlark.Value) (Value, error) {
+	nb := np.NewBuilder()
+	if err := assembleFrom(nb, val, BigIntError); err != nil {
+		return nil, fmt.Errorf("cannot convert %s to a datalark value: %w", val.Type(), err)
 	}
+	return ToValue(nb.Build())
 }
@@ -1,8 +1,11 @@
 package datalarkengine
 
 import (
-	"go.starlark.net/starlark"
+	"strings"
 	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"go.starlark.net/starlark"
 )
 
 func assertDatalark(t *testing.T, expect, actual Value) {
@@ -76,3 +79,111 @@ func TestStarlarkToDatalarkValue(t *testing.T) {
 	expectBytes := NewBytes([]byte{0x07, 0x08, 0x09})
 	assertDatalark(t, expectBytes, dv)
 }
+
+func TestStarlarkCompositesToDatalarkValue(t *testing.T) {
+	// Tuple, becomes a list
+	dv, err := starToHost(starlark.Tuple{starlark.MakeInt(1), starlark.String("a")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectList, err := NewList(starlark.NewList([]starlark.Value{starlark.MakeInt(1), starlark.String("a")}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertDatalark(t, expectList, dv)
+
+	// Set, becomes a list in insertion order
+	set := starlark.NewSet(2)
+	set.Insert(starlark.MakeInt(1))
+	set.Insert(starlark.String("a"))
+	dv, err = starToHost(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertDatalark(t, expectList, dv)
+
+	// Dict, nested, keeps its insertion order
+	inner := starlark.NewDict(1)
+	inner.SetKey(starlark.String("t"), starlark.Tuple{starlark.True})
+	dict := starlark.NewDict(2)
+	dict.SetKey(starlark.String("z"), starlark.MakeInt(1))
+	dict.SetKey(starlark.String("a"), inner)
+	dv, err = starToHost(dict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, dv.Type(), "datalark.Map")
+	assertEqual(t, dv.String(), `map{
+	string{"z"}: int{1}
+	string{"a"}: map{
+		string{"t"}: list{
+			0: bool{true}
+		}
+	}
+}`)
+
+	// Dict with keys that are not strings
+	dict = starlark.NewDict(1)
+	dict.SetKey(starlark.MakeInt(1), starlark.MakeInt(2))
+	_, err = starToHost(dict)
+	if err == nil {
+		t.Fatal("expected error, did not get one")
+	}
+
+	// Function, has no equivalent
+	_, err = starToHost(starlark.NewBuiltin("f", nil))
+	if err == nil {
+		t.Fatal("expected error, did not get one")
+	}
+	assertEqual(t, err.Error(), "cannot convert builtin_function_or_method to a datalark value")
+}
+
+// unorderedMapping is a mapping with no order to its keys, like a Go map
+type unorderedMapping map[string]starlark.Value
+
+func (m unorderedMapping) String() string        { return "unordered" }
+func (m unorderedMapping) Type() string          { return "unordered" }
+func (m unorderedMapping) Freeze()               {}
+func (m unorderedMapping) Truth() starlark.Bool  { return len(m) > 0 }
+func (m unorderedMapping) Hash() (uint32, error) { return 0, nil }
+func (m unorderedMapping) Get(k starlark.Value) (starlark.Value, bool, error) {
+	v, ok := m[string(k.(starlark.String))]
+	return v, ok, nil
+}
+func (m unorderedMapping) Items() []starlark.Tuple { return nil }
+func (m unorderedMapping) Iterate() starlark.Iterator {
+	keys := make([]starlark.Value, 0, len(m))
+	for k := range m {
+		keys = append(keys, starlark.String(k))
+	}
+	return starlark.Tuple(keys).Iterate()
+}
+
+func TestStarlarkUnorderedMappingIsSorted(t *testing.T) {
+	m := unorderedMapping{}
+	for _, k := range []string{"d", "b", "e", "a", "c"} {
+		m[k] = starlark.String(k)
+	}
+	dv, err := starToHost(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []string{}
+	for itr := dv.Node().MapIterator(); !itr.Done(); {
+		k, _, err := itr.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ks, _ := k.AsString()
+		keys = append(keys, ks)
+	}
+	assertEqual(t, strings.Join(keys, ","), "a,b,c,d,e")
+}
+
+func TestGuardBuiltinRecoversPanic(t *testing.T) {
+	fn := guardBuiltin(func(*starlark.Thread, *starlark.Builtin, starlark.Tuple, []starlark.Tuple) (starlark.Value, error) {
+		panic("unexpected node")
+	})
+	_, err := starlark.Call(&starlark.Thread{}, starlark.NewBuiltin("explode", fn), nil, nil)
+	qt.Assert(t, err, qt.ErrorMatches, `internal error in explode: unexpected node`)
+}
@@ -0,0 +1,239 @@
+package datalarkengine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/schema"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+type enumValue struct {
+	node datamodel.Node
+}
+
+var (
+	_ Value               = (*enumValue)(nil)
+	_ starlark.HasAttrs   = (*enumValue)(nil)
+	_ starlark.Comparable = (*enumValue)(nil)
+)
+
+func newEnumValue(node datamodel.Node) Value {
+	return &enumValue{node}
+}
+
+func (v *enumValue) Node() datamodel.Node {
+	return v.node
+}
+func (v *enumValue) Type() string {
+	return fmt.Sprintf("datalark.Enum<%s>", v.enumType().Name())
+}
+func (v *enumValue) String() string {
+	return sprintNode(v.node)
+}
+func (v *enumValue) Freeze() {}
+func (v *enumValue) Truth() starlark.Bool {
+	return true
+}
+func (v *enumValue) Hash() (uint32, error) {
+	// Enums only equal other enums, so hashing the type and member name is enough
+	name, err := v.memberName()
+	if err != nil {
+		return 0, err
+	}
+	return starlark.String(v.enumType().Name() + "." + name).Hash()
+}
+
+func (v *enumValue) enumType() *schema.TypeEnum {
+	return v.node.(schema.TypedNode).Type().(*schema.TypeEnum)
+}
+
+// memberName returns the type-level name of the member this value holds.
+func (v *enumValue) memberName() (string, error) {
+	return enumMemberOfNode(v.enumType(), v.node.(schema.TypedNode))
+}
+
+// starlark.Comparable
+
+func (v *enumValue) CompareSameType(op syntax.Token, y starlark.Value, depth int) (bool, error) {
+	other := y.(*enumValue)
+	left, err := v.memberName()
+	if err != nil {
+		return false, err
+	}
+	rite, err := other.memberName()
+	if err != nil {
+		return false, err
+	}
+	same := v.enumType().Name() == other.enumType().Name() && left == rite
+	switch op {
+	case syntax.EQL:
+		return same, nil
+	case syntax.NEQ:
+		return !same, nil
+	}
+	return false, fmt.Errorf("%s %s %s not implemented", v.Type(), op, y.Type())
+}
+
+// starlark.HasAttrs
+
+func (v *enumValue) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "name":
+		// Returned as a plain starlark string, because this is how enums are
+		// compared against strings: starlark only asks values of the same type
+		// whether they are equal, so `Color("Red") == "Red"` is always False
+		member, err := v.memberName()
+		if err != nil {
+			return starlark.None, err
+		}
+		return starlark.String(member), nil
+	case "members":
+		return enumMembersBuiltin(v.enumType()), nil
+	}
+	return nil, nil
+}
+
+func (v *enumValue) AttrNames() []string {
+	return []string{"members", "name"}
+}
+
+// enumMembersBuiltin returns a starlark function listing the members of an enum.
+func enumMembersBuiltin(typ *schema.TypeEnum) *starlark.Builtin {
+	return starlark.NewBuiltin("members", func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 0); err != nil {
+			return starlark.None, err
+		}
+		members := typ.Members()
+		res := make([]starlark.Value, len(members))
+		for i, m := range members {
+			res[i] = starlark.String(m)
+		}
+		return starlark.NewList(res), nil
+	})
+}
+
+// enumMemberOfNode finds the member name of an enum node by way of its
+// representation, which works regardless of what golang type it is bound to.
+func enumMemberOfNode(typ *schema.TypeEnum, tn schema.TypedNode) (string, error) {
+	repr := tn.Representation()
+	switch stg := typ.RepresentationStrategy().(type) {
+	case schema.EnumRepresentation_Int:
+		num, err := repr.AsInt()
+		if err != nil {
+			return "", err
+		}
+		return enumMemberFromInt(typ, stg, num)
+	case schema.EnumRepresentation_String:
+		str, err := repr.AsString()
+		if err != nil {
+			return "", err
+		}
+		return enumMemberFromString(typ, stg, str)
+	}
+	return "", fmt.Errorf("unsupported representation strategy for enum %s", typ.Name())
+}
+
+func enumMemberFromInt(typ *schema.TypeEnum, stg schema.EnumRepresentation_Int, num int64) (string, error) {
+	for _, member := range typ.Members() {
+		if reprNum, ok := stg[member]; ok && int64(reprNum) == num {
+			return member, nil
+		}
+	}
+	return "", fmt.Errorf("%d is not a valid representation of enum %s", num, typ.Name())
+}
+
+func enumMemberFromString(typ *schema.TypeEnum, stg schema.EnumRepresentation_String, str string) (string, error) {
+	for _, member := range typ.Members() {
+		if enumStringRepr(stg, member) == str {
+			return member, nil
+		}
+	}
+	return "", fmt.Errorf("%q is not a valid representation of enum %s", str, typ.Name())
+}
+
+// enumStringRepr returns the representation of a member, which defaults to
+// the member name itself when the schema doesn't say otherwise.
+func enumStringRepr(stg schema.EnumRepresentation_String, member string) string {
+	if mapped, ok := stg[member]; ok && mapped != "" {
+		return mapped
+	}
+	return member
+}
+
+func isEnumMember(typ *schema.TypeEnum, name string) bool {
+	for _, member := range typ.Members() {
+		if member == name {
+			return true
+		}
+	}
+	return false
+}
+
+// constructEnumValue creates an enum value from a single argument, which may be
+// the type-level member name, or the representation (a string or an int,
+// depending on the enum's representation strategy).
+func constructEnumValue(p *Prototype, tp schema.TypedPrototype, typ *schema.TypeEnum, argseq *ArgSeq) (starlark.Value, error) {
+	if !argseq.scalar {
+		return starlark.None, fmt.Errorf("enum %s must be constructed from a single value", typ.Name())
+	}
+	val := argseq.vals[0]
+
+	// another value of the same enum can simply be used as is
+	if ev, ok := val.(*enumValue); ok && ev.enumType().Name() == typ.Name() {
+		return ev, nil
+	}
+
+	// plain data held by a datalark value, such as a representation view
+	// from datalark.repr, is used like the starlark value it holds
+	if v, ok := val.(Value); ok && isUntyped(v) {
+		if text, err := v.Node().AsString(); err == nil {
+			val = starlark.String(text)
+		} else if num, err := v.Node().AsInt(); err == nil {
+			val = starlark.MakeInt64(num)
+		}
+	}
+
+	member := ""
+	switch sval := val.(type) {
+	case starlark.String:
+		name := string(sval)
+		if p.mode != ReprMode && isEnumMember(typ, name) {
+			member = name
+		} else if p.mode != TypedMode {
+			if stg, ok := typ.RepresentationStrategy().(schema.EnumRepresentation_String); ok {
+				member, _ = enumMemberFromString(typ, stg, name)
+			}
+		}
+	case starlark.Int:
+		if p.mode != TypedMode {
+			if stg, ok := typ.RepresentationStrategy().(schema.EnumRepresentation_Int); ok {
+				if num, ok := sval.Int64(); ok {
+					member, _ = enumMemberFromInt(typ, stg, num)
+				}
+			}
+		}
+	default:
+		return starlark.None, fmt.Errorf("cannot create enum %s from %v of type %s", typ.Name(), val, val.Type())
+	}
+	if member == "" {
+		return starlark.None, fmt.Errorf("%s is not a valid member of enum %s (members: %s)", val, typ.Name(), strings.Join(typ.Members(), ", "))
+	}
+
+	// Build via the representation: the type-level assembler of some node
+	// implementations (e.g. bindnode bound to an int) can't accept the member name.
+	nb := tp.Representation().NewBuilder()
+	switch stg := typ.RepresentationStrategy().(type) {
+	case schema.EnumRepresentation_Int:
+		if err := nb.AssignInt(int64(stg[member])); err != nil {
+			return starlark.None, err
+		}
+	case schema.EnumRepresentation_String:
+		if err := nb.AssignString(enumStringRepr(stg, member)); err != nil {
+			return starlark.None, err
+		}
+	}
+	return ToValue(nb.Build())
+}
@@ -0,0 +1,109 @@
+package datalarkengine
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/ipld/go-ipld-prime/node/bindnode"
+	"github.com/ipld/go-ipld-prime/schema"
+)
+
+func TestEnumConstruct(t *testing.T) {
+	mustParseSchemaRunScriptAssertOutput(t,
+		`
+		type Color enum {
+			| Red ("r")
+			| Green ("g")
+		}
+	`,
+		"mytypes",
+		`
+		print(mytypes.Color("Red"))
+		print(mytypes.Color("g"))
+		print(mytypes.Color.Typed("Green"))
+		print(mytypes.Color.Repr("r"))
+		print(mytypes.Color(mytypes.Color("Red")))
+	`, `
+		enum<Color>{"Red"}
+		enum<Color>{"Green"}
+		enum<Color>{"Green"}
+		enum<Color>{"Red"}
+		enum<Color>{"Red"}
+	`)
+}
+
+func TestEnumInsideContainers(t *testing.T) {
+	// the ipld printer can't print enums that are inside of other nodes,
+	// so they are printed by datalark, in the same style as on their own
+	mustParseSchemaRunScriptAssertOutput(t,
+		`
+		type Color enum {
+			| Red ("r")
+			| Green ("g")
+		}
+	`,
+		"mytypes",
+		`
+		print(datalark.List(_=[mytypes.Color("Red")]))
+		print(datalark.Map(a=mytypes.Color("g")))
+	`, `
+		list{
+			0: enum<Color>{"Red"}
+		}
+		map{
+			string{"a"}: enum<Color>{"Green"}
+		}
+	`)
+}
+
+func TestEnumConstructErrors(t *testing.T) {
+	defines := mustParseSchemaDefines(t,
+		`
+		type Color enum {
+			| Red ("r")
+			| Green ("g")
+		}
+	`)
+
+	_, err := runScript(defines, "mytypes", `
+		print(mytypes.Color("Purple"))
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `"Purple" is not a valid member of enum Color \(members: Red, Green\)`)
+
+	// only representations are accepted by Repr
+	_, err = runScript(defines, "mytypes", `
+		print(mytypes.Color.Repr("Red"))
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `"Red" is not a valid member of enum Color .*`)
+
+	// only member names are accepted by Typed
+	_, err = runScript(defines, "mytypes", `
+		print(mytypes.Color.Typed("r"))
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `"r" is not a valid member of enum Color .*`)
+
+	_, err = runScript(defines, "mytypes", `
+		print(mytypes.Color(1))
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `1 is not a valid member of enum Color .*`)
+}
+
+func TestEnumBoundToGoInt(t *testing.T) {
+	ts := schema.MustTypeSystem(
+		schema.SpawnEnum("Level", []string{"Low", "High"}, schema.EnumRepresentation_Int{"Low": 1, "High": 10}),
+	)
+	type Level int
+	defines := []schema.TypedPrototype{
+		bindnode.Prototype((*Level)(nil), ts.TypeByName("Level")),
+	}
+	assertScriptOutput(t, defines, "mytypes", `
+		lvl = mytypes.Level("High")
+		print(lvl)
+		print(lvl.name)
+		print(mytypes.Level(1).name)
+	`, `
+		enum<Level>{"High"}
+		High
+		Low
+	`)
+}
@@ -0,0 +1,60 @@
+package datalarkengine
+
+import (
+	"fmt"
+	"strings"
+
+	"go.starlark.net/starlark"
+)
+
+// pathError is an error from constructing a value, along with the path to
+// where it happened inside the value, such as `Outer.inner.field`. The path is
+// filled in as the error is returned up through each struct, map and list
+// being assembled, and then the type being constructed is added at the front
+type pathError struct {
+	path []string
+	err  error
+}
+
+func (e *pathError) Error() string {
+	return fmt.Sprintf("%s: %s", strings.Join(e.path, "."), e.err)
+}
+
+func (e *pathError) Unwrap() error {
+	return e.err
+}
+
+// atPath adds a segment to the front of the path of an error
+func atPath(err error, segment string) error {
+	if pe, ok := err.(*pathError); ok {
+		pe.path = append([]string{segment}, pe.path...)
+		return pe
+	}
+	return &pathError{path: []string{segment}, err: err}
+}
+
+// inType adds the name of the type being constructed to the front of the
+// path of an error, if it has one
+func inType(err error, typeName string) error {
+	if pe, ok := err.(*pathError); ok {
+		pe.path = append([]string{typeName}, pe.path...)
+	}
+	return err
+}
+
+// builtinFunc is the signature of the go functions behind starlark builtins
+type builtinFunc = func(*starlark.Thread, *starlark.Builtin, starlark.Tuple, []starlark.Tuple) (starlark.Value, error)
+
+// guardBuiltin wraps the function behind a builtin so that, like
+// CallInternal, a panic from deep inside ipld-prime is reported to the
+// script as an error, rather than taking down the host process
+func guardBuiltin(fn builtinFunc) builtinFunc {
+	return func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (val starlark.Value, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				val, err = starlark.None, fmt.Errorf("internal error in %s: %v", b.Name(), r)
+			}
+		}()
+		return fn(thread, b, args, kwargs)
+	}
+}
@@ -0,0 +1,183 @@
+package datalarkengine
+
+import (
+	"fmt"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"go.starlark.net/starlark"
+)
+
+// scripts are run against untrusted input, so no script may panic, no matter
+// how it misuses datalark values; these fuzz tests check for exactly that
+
+var fuzzSchema = `
+	type Foo struct {
+		a String
+		b String
+	} representation stringjoin {
+		join ":"
+	}
+	type Bar struct {
+		n Int
+		s optional String
+		items [Foo]
+	}
+	type Color enum {
+		| Red
+		| Green ("g")
+	} representation string
+	type Thing union {
+		| Foo "foo"
+		| Bar "bar"
+	} representation keyed
+	type Names [String]
+	type Ages {String:Int}
+	type Grid {Int:String}
+	type Ref &Any
+`
+
+var fuzzSeeds = []string{
+	`datalark.Int(3) + datalark.Float(2.5)`,
+	`datalark.Int(9223372036854775807) * 2`,
+	`-datalark.Int(-9223372036854775808)`,
+	`datalark.Int(3) // datalark.Int(0)`,
+	`datalark.String("a:b").partition(":")`,
+	`datalark.String("ab").elems()`,
+	`datalark.String("hello")[10:-20:-1]`,
+	`datalark.Bytes(b"\x01\x02")[5]`,
+	`datalark.Bytes(hex="zz")`,
+	`datalark.Bytes(multibase="")`,
+	`datalark.Int(3)[0:1]`,
+	`datalark.List(_=[1, 2, 3])[-4]`,
+	`datalark.List(_=[1, (2, 3)])`,
+	`datalark.List(_=[{"a": 1}])`,
+	`datalark.Map(a=1).update({"b": (1, 2)})`,
+	`datalark.Map(a={"b": [1, 2]})`,
+	`datalark.Map(_={1: 2})`,
+	`datalark.List(_=[1]).append({"x": 1})`,
+	`mytypes.Foo("a:b:c")`,
+	`mytypes.Foo(a=1, b=2)`,
+	`mytypes.Foo(1, 2, 3)`,
+	`mytypes.Foo()`,
+	`mytypes.Bar(n="x", items=[])`,
+	`mytypes.Bar(n=1, items=["a:b", 3])`,
+	`mytypes.Bar(n=1, items=["a:b"]).items[1]`,
+	`mytypes.Bar({"n": 1, "items": []}).Repr`,
+	`mytypes.Foo("a:b").Repr`,
+	`dir(mytypes.Bar(n=1, items=[]))`,
+	`mytypes.Color("Blue")`,
+	`mytypes.Color.Repr("Green")`,
+	`mytypes.Thing(foo="a:b")`,
+	`mytypes.Thing(foo="a:b", bar=1)`,
+	`mytypes.Thing()`,
+	`mytypes.Thing(datalark.Int(1))`,
+	`mytypes.Thing(Foo=mytypes.Foo("a:b")).Bar`,
+	`mytypes.Thing(Foo=mytypes.Foo("a:b")).match({"_": len})`,
+	`mytypes.Names("a", 1)`,
+	`mytypes.Names(_={"a": 1})`,
+	`mytypes.Ages(a="x")`,
+	`mytypes.Grid(_={"x": "y"})`,
+	`mytypes.Grid(_={1: "y"})[2]`,
+	`mytypes.Ref("not a cid")`,
+	`mytypes.Ref(1)`,
+	`datalark.Link("bafkqabiaaebagba").load_node()`,
+	`datalark.store(datalark.Int(1))`,
+	`datalark.codec.decode("{", "dag-json")`,
+	`datalark.codec.encode(datalark.Int(1), "nope")`,
+	`{datalark.Map(): 1}`,
+	`sorted([datalark.Int(1), datalark.String("a")])`,
+	`datalark.String("a") < datalark.Int(1)`,
+	`datalark.List(_=[1]) < datalark.List(_=["a"])`,
+	`datalark.Map(a=1).pop("b")`,
+	`datalark.Map().popitem()`,
+	`datalark.Map(a=1).fromkeys(1)`,
+	`[x for x in datalark.Map(a=1, b=2).items()]`,
+	`datalark.List(_=[mytypes.Color("Red")])`,
+	`datalark.Map(a=mytypes.Color("Green"))`,
+	`mytypes.Ages(a=1)[2]`,
+	`mytypes.Grid(_={1: "a"})[datalark.Float(1.5)]`,
+	`datalark.String("").nope()`,
+	`datalark.Bytes(b"").nope()`,
+	`datalark.schema("type Foo struct {")`,
+	`datalark.schema("type Foo union {} representation keyed").Foo()`,
+	`datalark.repr(mytypes.Thing(foo="a:b"))`,
+	`mytypes.Color.Repr(datalark.repr(mytypes.Color("Green")))`,
+	`mytypes.Bar.Repr(datalark.repr(mytypes.Foo("a:b")))`,
+	`mytypes.Bar(n=1, items=["a:b"]).replace(n=2, s="x")`,
+	`mytypes.Bar(n=1, items=["a:b"]).with_path("items.0.b", "c")`,
+	`mytypes.Thing(foo="a:b").with_path("Bar.n", 1)`,
+	`mytypes.Grid(_={1: "a"}).with_path("1", "b")`,
+	`datalark.List(_=[[1]]).with_path("0.-5", 2)`,
+}
+
+// runFuzzScript runs a script with the fuzz schema, with a limit on how much
+// work it may do, and fails the test if anything panics
+func runFuzzScript(t *testing.T, script string) {
+	defines := mustParseSchemaDefines(t, fuzzSchema)
+	globals := starlark.StringDict{
+		"datalark": PrimitiveConstructors(),
+		"mytypes":  MakeConstructors(defines),
+	}
+	thread := &starlark.Thread{
+		Name:  "fuzz",
+		Print: func(*starlark.Thread, string) {},
+	}
+	thread.SetMaxExecutionSteps(10000)
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("script panicked: %v\n%s", r, script)
+		}
+	}()
+	// errors are expected, only panics are failures
+	_, _ = starlark.ExecFile(thread, "fuzz.star", script, globals)
+}
+
+func FuzzScript(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(fmt.Sprintf("x = %s\nprint(x)\nprint(dir(x))\n", seed))
+	}
+	f.Fuzz(func(t *testing.T, script string) {
+		runFuzzScript(t, script)
+	})
+}
+
+func TestErrorsReportScriptPosition(t *testing.T) {
+	_, err := runScript(nil, "", `
+		x = datalark.Int(1)
+		y = datalark.Int("x")
+	`)
+	evalErr, ok := err.(*starlark.EvalError)
+	qt.Assert(t, ok, qt.IsTrue, qt.Commentf("got %T: %v", err, err))
+	qt.Assert(t, evalErr.Backtrace(), qt.Contains, "thefilename.star:2:")
+}
+
+var fuzzOperators = []string{"+", "-", "*", "/", "//", "%", "==", "!=", "<", ">=", "in", "not in", "[", "[::"}
+
+func FuzzBinaryOps(f *testing.F) {
+	values := []string{
+		`datalark.Int(7)`, `datalark.Float(-0.5)`, `datalark.String("ab")`,
+		`datalark.Bytes(b"\xff")`, `datalark.Bool(True)`, `datalark.List(_=[1, "a"])`,
+		`datalark.Map(a=1)`, `mytypes.Foo("a:b")`, `mytypes.Color("Red")`, `mytypes.Names("x")`,
+		`mytypes.Ref("bafkqabiaaebagba")`, `datalark.Int(-9223372036854775808)`,
+		`3`, `-1`, `"s"`, `b"b"`, `[1]`, `{"a": 1}`, `(1,)`, `None`,
+	}
+	for i, left := range values {
+		for j := range fuzzOperators {
+			f.Add(left, uint8(j), values[(i+j)%len(values)])
+		}
+	}
+	f.Fuzz(func(t *testing.T, left string, op uint8, right string) {
+		var expr string
+		switch oper := fuzzOperators[int(op)%len(fuzzOperators)]; oper {
+		case "[":
+			expr = fmt.Sprintf("(%s)[%s]", left, right)
+		case "[::":
+			expr = fmt.Sprintf("(%s)[%s::%s]", left, right, right)
+		default:
+			expr = fmt.Sprintf("(%s) %s (%s)", left, oper, right)
+		}
+		runFuzzScript(t, fmt.Sprintf("x = %s\nprint(x)\n", expr))
+	})
+}
@@ -70,12 +70,15 @@ func Example_mapWithStructKeys() {
 	},
 		"mytypes",
 		`
-		#print(mytypes.Map__FooBar__String({"f:b": "wot"})) # I want this to work someday, but it's not quite that magic yet.
 		print(mytypes.Map__FooBar__String(_={mytypes.FooBar(foo="f", bar="b"): "wot"}))
+		print(mytypes.Map__FooBar__String(_={"f:b": "wot"}))
 	`)
 
 	// Output:
 	// map<Map__FooBar__String>{
 	// 	struct<FooBar>{foo: string<String>{"f"}, bar: string<String>{"b"}}: string<String>{"wot"}
 	// }
+	// map<Map__FooBar__String>{
+	// 	struct<FooBar>{foo: string<String>{"f"}, bar: string<String>{"b"}}: string<String>{"wot"}
+	// }
 }
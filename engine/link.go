@@ -0,0 +1,140 @@
+package datalarkengine
+
+import (
+	"fmt"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/ipld/go-ipld-prime/schema"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// linkValue wraps a node of kind link, and exposes the parts of its CID as attributes
+type linkValue struct {
+	node datamodel.Node
+}
+
+var (
+	_ Value               = (*linkValue)(nil)
+	_ starlark.HasAttrs   = (*linkValue)(nil)
+	_ starlark.Comparable = (*linkValue)(nil)
+)
+
+func newLinkValue(node datamodel.Node) Value {
+	return &linkValue{node}
+}
+
+// NewLink constructs a Link Value
+func NewLink(x datamodel.Link) Value {
+	return newLinkValue(basicnode.NewLink(x))
+}
+
+func (v *linkValue) Node() datamodel.Node {
+	return v.node
+}
+func (v *linkValue) Type() string {
+	if typed, ok := v.node.(schema.TypedNode); ok {
+		return fmt.Sprintf("datalark.link<%s>", typed.Type().Name())
+	}
+	return "datalark.link"
+}
+func (v *linkValue) String() string {
+	return sprintNode(v.node)
+}
+func (v *linkValue) Freeze() {}
+func (v *linkValue) Truth() starlark.Bool {
+	return true
+}
+func (v *linkValue) Hash() (uint32, error) {
+	lnk, err := v.node.AsLink()
+	if err != nil {
+		return 0, err
+	}
+	// the same hash as the string form of the CID
+	return starlark.String(lnk.String()).Hash()
+}
+
+// starlark.Comparable
+
+func (v *linkValue) CompareSameType(op syntax.Token, y starlark.Value, depth int) (bool, error) {
+	left, err := v.node.AsLink()
+	if err != nil {
+		return false, err
+	}
+	rite, err := y.(*linkValue).node.AsLink()
+	if err != nil {
+		return false, err
+	}
+	same := left.Binary() == rite.Binary()
+	switch op {
+	case syntax.EQL:
+		return same, nil
+	case syntax.NEQ:
+		return !same, nil
+	}
+	return false, fmt.Errorf("%s %s %s not implemented", v.Type(), op, y.Type())
+}
+
+// starlark.HasAttrs
+
+var linkAttrNames = []string{"cid", "codec", "hash_function", "load_node", "multihash", "version"}
+
+func (v *linkValue) Attr(name string) (starlark.Value, error) {
+	if name == "load_node" {
+		return starlark.NewBuiltin("load_node", guardBuiltin(linkMethodLoadNode)).BindReceiver(v), nil
+	}
+	lnk, err := v.node.AsLink()
+	if err != nil {
+		return starlark.None, err
+	}
+	if name == "cid" {
+		return starlark.String(lnk.String()), nil
+	}
+	cl, ok := lnk.(cidlink.Link)
+	if !ok {
+		return starlark.None, fmt.Errorf("link of type %T has no attribute %s", lnk, name)
+	}
+	switch name {
+	case "version":
+		return starlark.MakeUint64(cl.Cid.Version()), nil
+	case "codec":
+		return starlark.MakeUint64(cl.Cid.Prefix().Codec), nil
+	case "hash_function":
+		return starlark.MakeUint64(cl.Cid.Prefix().MhType), nil
+	case "multihash":
+		return starlark.Bytes(cl.Cid.Hash()), nil
+	}
+	return nil, nil
+}
+
+func (v *linkValue) AttrNames() []string {
+	return linkAttrNames
+}
+
+// constructLinkValue creates a link from a single argument, which may be
+// either an existing link value or a string form of a CID
+func constructLinkValue(p *Prototype, argseq *ArgSeq) (starlark.Value, error) {
+	if !argseq.scalar {
+		return starlark.None, fmt.Errorf("wrong arguments for link constructor")
+	}
+	nb := p.np.NewBuilder()
+	val := argseq.vals[0]
+	switch it := val.(type) {
+	case starlark.String:
+		c, err := cid.Decode(string(it))
+		if err != nil {
+			return starlark.None, fmt.Errorf("cannot create %s from %v: %w", p.TypeName(), val, err)
+		}
+		if err := nb.AssignLink(cidlink.Link{Cid: c}); err != nil {
+			return starlark.None, err
+		}
+	default:
+		if err := assembleFrom(nb, val, argseq.bigInts); err != nil {
+			return starlark.None, fmt.Errorf("cannot create %s from %v of type %s", p.TypeName(), val, val.Type())
+		}
+	}
+	return ToValue(nb.Build())
+}
@@ -0,0 +1,40 @@
+package datalarkengine
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/fluent/qp"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"go.starlark.net/starlark"
+)
+
+func TestLinkFromNode(t *testing.T) {
+	n, err := qp.BuildMap(basicnode.Prototype.Map, 1, func(ma datamodel.MapAssembler) {
+		qp.MapEntry(ma, "parent", qp.Link(newTestLink()))
+	})
+	qt.Assert(t, err, qt.IsNil)
+
+	val, err := ToValue(n)
+	qt.Assert(t, err, qt.IsNil)
+	lnk, _, err := val.(*mapValue).Get(starlark.String("parent"))
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, lnk.Type(), qt.Equals, "datalark.link")
+
+	cidStr, err := lnk.(*linkValue).Attr("cid")
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, cidStr, qt.Equals, starlark.String("bafkqabiaaebagba"))
+}
+
+func TestLinkConstructErrors(t *testing.T) {
+	_, err := runScript(nil, "", `
+		print(datalark.Link("not-a-cid"))
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `cannot create Link from "not-a-cid": .*`)
+
+	_, err = runScript(nil, "", `
+		print(datalark.Link(4))
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `cannot create Link from 4 of type int`)
+}
@@ -0,0 +1,198 @@
+package datalarkengine
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/linking"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/ipld/go-ipld-prime/schema"
+	"github.com/multiformats/go-multihash"
+	"go.starlark.net/starlark"
+)
+
+// threadLocalLinkSystem is the key under which a LinkSystem is stored in a starlark.Thread
+const threadLocalLinkSystem = "datalark.LinkSystem"
+
+// See docs on datalark.SetLinkSystem.
+//
+// The LinkSystem is kept on the thread (rather than on the constructors)
+// because link values can turn up anywhere in data, long after the
+// constructor that made their parent has been forgotten about;
+// the thread is the one thing that's always on hand when starlark calls a method.
+func SetLinkSystem(thread *starlark.Thread, lsys *linking.LinkSystem) {
+	thread.SetLocal(threadLocalLinkSystem, lsys)
+}
+
+func linkSystemFor(thread *starlark.Thread) (*linking.LinkSystem, error) {
+	if thread != nil {
+		if lsys, ok := thread.Local(threadLocalLinkSystem).(*linking.LinkSystem); ok && lsys != nil {
+			return lsys, nil
+		}
+	}
+	return nil, fmt.Errorf("no LinkSystem is available; the host must provide one using datalark.SetLinkSystem")
+}
+
+// linkMethodLoadNode loads the node that a link points to, returning it as a Value.
+// The optional argument is a Prototype to load the data as, giving typed results.
+//
+// (It can't simply be called "load", because that's a keyword in starlark.)
+func linkMethodLoadNode(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var proto *Prototype
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "prototype?", &proto); err != nil {
+		return starlark.None, err
+	}
+	lsys, err := linkSystemFor(thread)
+	if err != nil {
+		return starlark.None, err
+	}
+	lnk, err := b.Receiver().(*linkValue).node.AsLink()
+	if err != nil {
+		return starlark.None, err
+	}
+
+	// untyped data can be anything at all; typed data is decoded via its representation
+	var np datamodel.NodePrototype = basicnode.Prototype.Any
+	if proto != nil {
+		np = proto.np
+		if tp, ok := np.(schema.TypedPrototype); ok {
+			np = tp.Representation()
+		}
+	}
+
+	n, err := lsys.Load(linking.LinkContext{Ctx: context.Background()}, lnk, np)
+	if err != nil {
+		return starlark.None, err
+	}
+	return ToValue(n)
+}
+
+// threadLocalLinkPrototype is the key under which the default LinkPrototype is stored in a starlark.Thread
+const threadLocalLinkPrototype = "datalark.LinkPrototype"
+
+// defaultLinkPrototype is used for storing when the host hasn't set one: CIDv1, dag-cbor, sha2-256.
+var defaultLinkPrototype = cidlink.LinkPrototype{Prefix: cid.Prefix{
+	Version:  1,
+	Codec:    0x71,
+	MhType:   multihash.SHA2_256,
+	MhLength: -1,
+}}
+
+// See docs on datalark.SetLinkPrototype.
+func SetLinkPrototype(thread *starlark.Thread, lp datamodel.LinkPrototype) {
+	thread.SetLocal(threadLocalLinkPrototype, lp)
+}
+
+func linkPrototypeFor(thread *starlark.Thread) datamodel.LinkPrototype {
+	if thread != nil {
+		if lp, ok := thread.Local(threadLocalLinkPrototype).(datamodel.LinkPrototype); ok && lp != nil {
+			return lp
+		}
+	}
+	return defaultLinkPrototype
+}
+
+// storeFunc stores a value using the thread's LinkSystem, and returns a link to it.
+// The codec, hasher, and CID version can each be overridden per call.
+func storeFunc(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var starVal, starCodec, starHasher starlark.Value
+	var version int = -1
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "value", &starVal, "codec?", &starCodec, "hasher?", &starHasher, "version?", &version); err != nil {
+		return starlark.None, err
+	}
+	lsys, err := linkSystemFor(thread)
+	if err != nil {
+		return starlark.None, err
+	}
+	lp, err := overrideLinkPrototype(linkPrototypeFor(thread), starCodec, starHasher, version)
+	if err != nil {
+		return starlark.None, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+
+	// typed data is always stored in its representation form
+	node, err := nodeForStoring(starVal, bigIntPolicyFor(thread))
+	if err != nil {
+		return starlark.None, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+	if tn, ok := node.(schema.TypedNode); ok {
+		node = tn.Representation()
+	}
+
+	lnk, err := lsys.Store(linking.LinkContext{Ctx: context.Background()}, lp, node)
+	if err != nil {
+		return starlark.None, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+	return NewLink(lnk), nil
+}
+
+// nodeForStoring gets a node from either a datalark Value, or from plain starlark data,
+// in which ints that are too big are handled by the given policy
+func nodeForStoring(starVal starlark.Value, bigInts BigIntPolicy) (datamodel.Node, error) {
+	if hostVal, ok := starVal.(Value); ok {
+		return hostVal.Node(), nil
+	}
+	nb := basicnode.Prototype.Any.NewBuilder()
+	if err := assembleFrom(nb, starVal, bigInts); err != nil {
+		return nil, err
+	}
+	return nb.Build(), nil
+}
+
+func overrideLinkPrototype(lp datamodel.LinkPrototype, starCodec, starHasher starlark.Value, version int) (datamodel.LinkPrototype, error) {
+	if starCodec == nil && starHasher == nil && version == -1 {
+		return lp, nil
+	}
+	clp, ok := lp.(cidlink.LinkPrototype)
+	if !ok {
+		return nil, fmt.Errorf("cannot override parameters of a link prototype of type %T", lp)
+	}
+	if starCodec != nil {
+		code, err := lookupCode(starCodec, multicodecNames, "codec")
+		if err != nil {
+			return nil, err
+		}
+		clp.Codec = code
+	}
+	if starHasher != nil {
+		code, err := lookupCode(starHasher, multihash.Names, "hasher")
+		if err != nil {
+			return nil, err
+		}
+		clp.MhType = code
+		clp.MhLength = -1
+	}
+	switch version {
+	case -1:
+		// unchanged
+	case 0, 1:
+		clp.Version = uint64(version)
+	default:
+		return nil, fmt.Errorf("invalid CID version %d", version)
+	}
+	if clp.Version == 0 && (clp.Codec != 0x70 || clp.MhType != multihash.SHA2_256) {
+		return nil, fmt.Errorf("CIDv0 can only be used with the dag-pb codec and sha2-256 hasher")
+	}
+	return clp, nil
+}
+
+// lookupCode gets a multicodec indicator from either its name or its number
+func lookupCode(starVal starlark.Value, names map[string]uint64, what string) (uint64, error) {
+	switch it := starVal.(type) {
+	case starlark.String:
+		code, ok := names[string(it)]
+		if !ok {
+			return 0, fmt.Errorf("unknown %s %q", what, string(it))
+		}
+		return code, nil
+	case starlark.Int:
+		code, ok := it.Uint64()
+		if !ok {
+			return 0, fmt.Errorf("invalid %s %v", what, it)
+		}
+		return code, nil
+	}
+	return 0, fmt.Errorf("%s must be a name or a number, got %s", what, starVal.Type())
+}
@@ -0,0 +1,161 @@
+package datalarkengine
+
+import (
+	"context"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/fluent/qp"
+	"github.com/ipld/go-ipld-prime/linking"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/ipld/go-ipld-prime/storage/memstore"
+	"go.starlark.net/starlark"
+
+	"github.com/ipld/go-datalark/testutil"
+)
+
+// newTestLinkSystem returns a LinkSystem backed by an in-memory store
+func newTestLinkSystem() *linking.LinkSystem {
+	store := &memstore.Store{}
+	lsys := cidlink.DefaultLinkSystem()
+	lsys.SetReadStorage(store)
+	lsys.SetWriteStorage(store)
+	return &lsys
+}
+
+var testLinkPrototype = cidlink.LinkPrototype{Prefix: cid.Prefix{
+	Version:  1,
+	Codec:    0x0129, // dag-json
+	MhType:   0x12,   // sha2-256
+	MhLength: 32,
+}}
+
+func TestLinkLoad(t *testing.T) {
+	lsys := newTestLinkSystem()
+	ctx := linking.LinkContext{Ctx: context.Background()}
+
+	leaf, err := qp.BuildMap(basicnode.Prototype.Map, 2, func(ma datamodel.MapAssembler) {
+		qp.MapEntry(ma, "foo", qp.String("one"))
+		qp.MapEntry(ma, "bar", qp.String("two"))
+	})
+	qt.Assert(t, err, qt.IsNil)
+	leafLink, err := lsys.Store(ctx, testLinkPrototype, leaf)
+	qt.Assert(t, err, qt.IsNil)
+
+	root, err := qp.BuildMap(basicnode.Prototype.Map, 1, func(ma datamodel.MapAssembler) {
+		qp.MapEntry(ma, "child", qp.Link(leafLink))
+	})
+	qt.Assert(t, err, qt.IsNil)
+	rootLink, err := lsys.Store(ctx, testLinkPrototype, root)
+	qt.Assert(t, err, qt.IsNil)
+
+	defines := mustParseSchemaDefines(t, `
+		type FooBar struct {
+			foo String
+			bar String
+		}
+	`)
+	output, err := runScript(defines, "mytypes", `
+		r = root.load_node()
+		print(r)
+		print(r["child"].load_node())
+		print(r["child"].load_node(mytypes.FooBar).foo)
+	`, withLinkSystem(lsys), withGlobals(starlark.StringDict{"root": NewLink(rootLink)}))
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, output, qt.Equals, testutil.Dedent(`
+		map{
+			string{"child"}: link{`+leafLink.String()+`}
+		}
+		map{
+			string{"bar"}: string{"two"}
+			string{"foo"}: string{"one"}
+		}
+		string<String>{"one"}
+	`))
+}
+
+func TestLinkLoadErrors(t *testing.T) {
+	// no LinkSystem bound
+	_, err := runScript(nil, "", `
+		lnk.load_node()
+	`, withGlobals(starlark.StringDict{"lnk": NewLink(newTestLink())}))
+	qt.Assert(t, err, qt.ErrorMatches, `no LinkSystem is available.*`)
+
+	// data not found in storage
+	_, err = runScript(nil, "", `
+		lnk.load_node()
+	`, withLinkSystem(newTestLinkSystem()), withGlobals(starlark.StringDict{"lnk": NewLink(newTestLink())}))
+	qt.Assert(t, err, qt.Not(qt.IsNil))
+}
+
+func TestStore(t *testing.T) {
+	lsys := newTestLinkSystem()
+	defines := mustParseSchemaDefines(t, `
+		type FooBar struct {
+			foo String
+			bar String
+		} representation tuple
+	`)
+	output, err := runScript(defines, "mytypes", `
+		lnk = datalark.store(mytypes.FooBar(foo="one", bar="two"))
+		print(lnk)
+		print(lnk.load_node())
+		print(lnk.load_node(mytypes.FooBar))
+		print(datalark.store({"a": [1, 2]}, codec="dag-json"))
+		print(datalark.store(datalark.String("hi"), codec=0x0129, hasher="sha2-512").hash_function)
+	`, withLinkSystem(lsys))
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, output, qt.Equals, testutil.Dedent(`
+		link{bafyreid2ugn2d7fott3hc4t4776zbd24ko55drsm74kgksanfyq33fuoxm}
+		list{
+			0: string{"one"}
+			1: string{"two"}
+		}
+		struct<FooBar>{
+			foo: string<String>{"one"}
+			bar: string<String>{"two"}
+		}
+		link{baguqeeraafjq2fsni6opbdrg2oy23g63vetredmx4lifpjwxslnxpb4a24qa}
+		19
+	`))
+}
+
+func TestStoreUsingHostLinkPrototype(t *testing.T) {
+	lsys := newTestLinkSystem()
+	output, err := runScript(nil, "", `
+		print(datalark.store(datalark.String("hi")).codec)
+	`, withLinkSystem(lsys))
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, output, qt.Equals, "113\n")
+
+	thread := &starlark.Thread{}
+	SetLinkSystem(thread, lsys)
+	SetLinkPrototype(thread, testLinkPrototype)
+	store, err := PrimitiveConstructors().Attr("store")
+	qt.Assert(t, err, qt.IsNil)
+	val, err := starlark.Call(thread, store, starlark.Tuple{starlark.String("hi")}, nil)
+	qt.Assert(t, err, qt.IsNil)
+	codec, err := val.(*linkValue).Attr("codec")
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, codec, qt.Equals, starlark.MakeInt(0x0129))
+}
+
+func TestStoreErrors(t *testing.T) {
+	_, err := runScript(nil, "", `
+		datalark.store("hi")
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `no LinkSystem is available.*`)
+
+	_, err = runScript(nil, "", `
+		datalark.store("hi", codec="bogus")
+	`, withLinkSystem(newTestLinkSystem()))
+	qt.Assert(t, err, qt.ErrorMatches, `store: unknown codec "bogus"`)
+
+	_, err = runScript(nil, "", `
+		datalark.store("hi", version=0)
+	`, withLinkSystem(newTestLinkSystem()))
+	qt.Assert(t, err, qt.ErrorMatches, `store: CIDv0 can only be used with .*`)
+}
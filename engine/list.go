@@ -1,30 +1,69 @@
 package datalarkengine
 
 import (
-	"errors"
 	"fmt"
 	"sort"
+	"strconv"
 
 	"github.com/ipld/go-ipld-prime/datamodel"
 	"github.com/ipld/go-ipld-prime/node/basicnode"
-	"github.com/ipld/go-ipld-prime/printer"
+	"github.com/ipld/go-ipld-prime/schema"
 	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
 )
 
 type listValue struct {
 	node   datamodel.Node
 	suffix []datamodel.Node
+	// elems are the elements of the node and then of the suffix, converted
+	// when the list value is made and kept up to date by each change, so that
+	// indexing and iterating, which can't report errors, never read the node.
+	// Every read of an element returns the same value, so they are frozen
+	elems []starlark.Value
+	// itercount is the number of active iterators, while
+	// there are any, the list must not be mutated
+	itercount uint32
+	frozen    bool
 }
 
 var (
 	_ Value                = (*listValue)(nil)
 	_ starlark.Indexable   = (*listValue)(nil)
+	_ starlark.Sliceable   = (*listValue)(nil)
 	_ starlark.Sequence    = (*listValue)(nil)
 	_ starlark.HasSetIndex = (*listValue)(nil)
+	_ starlark.HasBinary   = (*listValue)(nil)
+	_ starlark.Comparable  = (*listValue)(nil)
 )
 
-func newListValue(node datamodel.Node) Value {
-	return &listValue{node, nil}
+func newListValue(node datamodel.Node) (Value, error) {
+	v := &listValue{node: node}
+	elems := make([]starlark.Value, 0, node.Length())
+	iter := node.ListIterator()
+	for iter != nil && !iter.Done() {
+		seg := strconv.Itoa(len(elems))
+		_, item, err := iter.Next()
+		if err != nil {
+			return nil, atPath(err, seg)
+		}
+		elem, err := elementValue(item)
+		if err != nil {
+			return nil, atPath(err, seg)
+		}
+		elems = append(elems, elem)
+	}
+	v.elems = elems
+	return v, nil
+}
+
+// elementValue converts a node into the value kept for it in a list
+func elementValue(item datamodel.Node) (starlark.Value, error) {
+	val, err := ToValue(item)
+	if err != nil {
+		return nil, err
+	}
+	val.Freeze()
+	return val, nil
 }
 
 func (v *listValue) Node() datamodel.Node {
@@ -32,20 +71,26 @@ func (v *listValue) Node() datamodel.Node {
 	return v.node
 }
 func (v *listValue) Type() string {
-	// TODO(dustmop): Can a list be a TypedNode? I believe so, it
-	// is used for a homogeneous typed list.
+	if tn, ok := v.node.(schema.TypedNode); ok {
+		return fmt.Sprintf("datalark.List<%s>", typeDisplayName(tn.Type()))
+	}
 	return fmt.Sprintf("datalark.List")
 }
 func (v *listValue) String() string {
 	v.applyChangesToNode()
-	return printer.Sprint(v.node)
+	return sprintNode(v.node)
+}
+func (v *listValue) Freeze() {
+	v.frozen = true
 }
-func (v *listValue) Freeze() {}
 func (v *listValue) Truth() starlark.Bool {
 	return true
 }
+
+// Hash returns an error, because like starlark lists, lists are not hashable,
+// even once frozen.
 func (v *listValue) Hash() (uint32, error) {
-	return 0, errors.New("TODO")
+	return 0, fmt.Errorf("unhashable type: %s", v.Type())
 }
 
 // NewList converts a starlark.List into a datalark.Value
@@ -58,20 +103,104 @@ func NewList(starList *starlark.List) (Value, error) {
 	}
 	for i := 0; i < size; i++ {
 		item := starList.Index(i)
-		if err := assembleFrom(la.AssembleValue(), item); err != nil {
+		if err := assembleFrom(la.AssembleValue(), item, BigIntError); err != nil {
 			return nil, fmt.Errorf("cannot add %v of type %T", item, item)
 		}
 	}
 	if err := la.Finish(); err != nil {
 		return nil, err
 	}
-	return newListValue(nb.Build()), nil
+	return newListValue(nb.Build())
 }
 
 // starlark.Sequence
 
+// Iterate returns an iterator over the elements of the list, including pending
+// changes. While any iterator is active, the list cannot be mutated.
 func (v *listValue) Iterate() starlark.Iterator {
-	panic(fmt.Errorf("TODO(dustmop): listValue.Iterate not implemented for %T", v))
+	v.itercount++
+	return &listIterator{lv: v}
+}
+
+type listIterator struct {
+	lv    *listValue
+	index int
+}
+
+func (it *listIterator) Next(p *starlark.Value) bool {
+	if it.index >= it.lv.Len() {
+		return false
+	}
+	*p = it.lv.Index(it.index)
+	it.index++
+	return true
+}
+
+func (it *listIterator) Done() {
+	it.lv.itercount--
+}
+
+// starlark.Comparable
+
+// CompareSameType compares lists element by element, the same way as starlark lists
+func (v *listValue) CompareSameType(op syntax.Token, y starlark.Value, depth int) (bool, error) {
+	other := y.(*listValue)
+	xlen, ylen := v.Len(), other.Len()
+
+	// fast path for equality of lists with different lengths
+	if xlen != ylen {
+		if op == syntax.EQL {
+			return false, nil
+		}
+		if op == syntax.NEQ {
+			return true, nil
+		}
+	}
+
+	// find the first element that differs, and compare using it
+	for i := 0; i < xlen && i < ylen; i++ {
+		left, right := v.Index(i), other.Index(i)
+		if eq, err := starlark.EqualDepth(left, right, depth-1); err != nil {
+			return false, err
+		} else if !eq {
+			return starlark.CompareDepth(op, left, right, depth-1)
+		}
+	}
+
+	// otherwise, compare the lengths
+	switch op {
+	case syntax.EQL:
+		return xlen == ylen, nil
+	case syntax.NEQ:
+		return xlen != ylen, nil
+	case syntax.LT:
+		return xlen < ylen, nil
+	case syntax.LE:
+		return xlen <= ylen, nil
+	case syntax.GT:
+		return xlen > ylen, nil
+	case syntax.GE:
+		return xlen >= ylen, nil
+	}
+	return false, fmt.Errorf("%s %s %s not implemented", v.Type(), op, y.Type())
+}
+
+// starlark.HasBinary
+
+// Binary implements the "in" operator, with the list on the right side
+func (v *listValue) Binary(op syntax.Token, y starlark.Value, side starlark.Side) (starlark.Value, error) {
+	if op != syntax.IN || side != starlark.Right {
+		return nil, nil
+	}
+	hostElem, err := starToHost(y)
+	if err != nil {
+		return nil, err
+	}
+	index, err := findFirstLoc(v, hostElem)
+	if err != nil {
+		return nil, err
+	}
+	return starlark.Bool(index != -1), nil
 }
 
 func (v *listValue) Len() int {
@@ -80,25 +209,77 @@ func (v *listValue) Len() int {
 
 // starlark.Indexable
 
+// Index returns the element at index i. Starlark has already checked i against
+// Len, and made negative indices relative to the end of the list, before calling
+// it. Index has no way to return an error, so it returns the element that was
+// converted when it was added to the list
 func (v *listValue) Index(i int) starlark.Value {
-	totalLen := int(v.node.Length()) + len(v.suffix)
-	if i >= totalLen {
-		panic(fmt.Errorf("index out of range, index = %d, len = %d", i, totalLen))
+	return v.elems[i]
+}
+
+// starlark.Sliceable
+
+// Slice returns a new list with the elements from start to end, stepping by step.
+// The elements are kept as the suffix of an empty list of the same prototype, so
+// a typed list stays typed, without assembling them again
+func (v *listValue) Slice(start, end, step int) starlark.Value {
+	var items []datamodel.Node
+	var elems []starlark.Value
+	for i := start; (step > 0 && i < end) || (step < 0 && i > end); i += step {
+		items = append(items, v.elems[i].(Value).Node())
+		elems = append(elems, v.elems[i])
+	}
+	build := &listValue{node: v.node}
+	build.clear()
+	build.suffix = items
+	build.elems = elems
+	return build
+}
+
+// nodeAt returns the node at index i, which may be in the node or in the suffix
+func (v *listValue) nodeAt(i int) (datamodel.Node, error) {
+	totalLen := v.Len()
+	if i < 0 || i >= totalLen {
+		return nil, fmt.Errorf("index out of range, index = %d, len = %d", i, totalLen)
 	}
 	if i < int(v.node.Length()) {
-		item, err := v.node.LookupByIndex(int64(i))
-		if err != nil {
-			panic(err)
+		return v.node.LookupByIndex(int64(i))
+	}
+	return v.suffix[i-int(v.node.Length())], nil
+}
+
+// buildListFrom builds a list of the given prototype, with the items as its elements
+func buildListFrom(np datamodel.NodePrototype, items []datamodel.Node) (datamodel.Node, error) {
+	nb := np.NewBuilder()
+	la, err := nb.BeginList(int64(len(items)))
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if err := la.AssembleValue().AssignNode(item); err != nil {
+			return nil, err
 		}
-		return nodeToHost(item)
 	}
-	j := i - int(v.node.Length())
-	return nodeToHost(v.suffix[j])
+	if err := la.Finish(); err != nil {
+		return nil, err
+	}
+	return nb.Build(), nil
 }
 
 // starlark.HasSetIndex
 
 func (v *listValue) SetIndex(i int, value starlark.Value) error {
+	if err := v.checkMutable("assign to element of"); err != nil {
+		return err
+	}
+	nodeItem, err := v.elementNode(value, BigIntError)
+	if err != nil {
+		return err
+	}
+	elem, err := elementValue(nodeItem)
+	if err != nil {
+		return err
+	}
 	if i < int(v.node.Length()) {
 		// if assigning within the node, split it
 		node, nodeList, err := v.splitNodeAtIndex(int64(i))
@@ -108,14 +289,11 @@ func (v *listValue) SetIndex(i int, value starlark.Value) error {
 		v.node = node
 		v.suffix = append(nodeList, v.suffix...)
 	}
+	v.elems[i] = elem
 
 	// calculate index into the suffix alone
 	i = i - int(v.node.Length())
-	hostItem, err := starToHost(value)
-	if err != nil {
-		return err
-	}
-	v.suffix[i] = hostItem.Node()
+	v.suffix[i] = nodeItem
 	return nil
 }
 
@@ -139,33 +317,47 @@ func (v *listValue) AttrNames() []string {
 
 // utility
 
+// checkMutable returns an error if the list cannot be modified right now,
+// which is the case once it is frozen, or while it is being iterated
+func (v *listValue) checkMutable(verb string) error {
+	if v.frozen {
+		return fmt.Errorf("cannot %s frozen list", verb)
+	}
+	if v.itercount > 0 {
+		return fmt.Errorf("cannot %s list during iteration", verb)
+	}
+	return nil
+}
+
 func (v *listValue) clear() {
 	nb := v.node.Prototype().NewBuilder()
 	la, _ := nb.BeginList(0)
 	_ = la.Finish()
 	v.node = nb.Build()
 	v.suffix = nil
+	v.elems = nil
 }
 
 // methods
 
-type listMethod func(*listValue, []starlark.Value) (starlark.Value, error)
+type listMethod func(*starlark.Thread, *listValue, []starlark.Value) (starlark.Value, error)
 
 var listMethods = map[string]*starlark.Builtin{
-	"append":  NewListMethod("append", listMethodAppend, 1, 1),
-	"clear":   NewListMethod("clear", listMethodClear, 0, 0),
-	"copy":    NewListMethod("copy", listMethodCopy, 0, 0),
-	"count":   NewListMethod("count", listMethodCount, 1, 1),
-	"extend":  NewListMethod("extend", listMethodExtend, 1, 1),
-	"index":   NewListMethod("index", listMethodIndex, 1, 1),
-	"insert":  NewListMethod("insert", listMethodInsert, 2, 2),
-	"remove":  NewListMethod("remove", listMethodRemove, 1, 1),
-	"reverse": NewListMethod("reverse", listMethodReverse, 0, 0),
-	"sort":    NewListMethod("sort", listMethodSort, 0, 2),
+	"append":    NewListMethod("append", listMethodAppend, 1, 1),
+	"clear":     NewListMethod("clear", listMethodClear, 0, 0),
+	"copy":      NewListMethod("copy", listMethodCopy, 0, 0),
+	"count":     NewListMethod("count", listMethodCount, 1, 1),
+	"extend":    NewListMethod("extend", listMethodExtend, 1, 1),
+	"index":     NewListMethod("index", listMethodIndex, 1, 1),
+	"insert":    NewListMethod("insert", listMethodInsert, 2, 2),
+	"remove":    NewListMethod("remove", listMethodRemove, 1, 1),
+	"reverse":   NewListMethod("reverse", listMethodReverse, 0, 0),
+	"sort":      NewListMethod("sort", listMethodSort, 0, 2),
+	"with_path": NewListMethod("with_path", listMethodWithPath, 2, 2),
 }
 
 func NewListMethod(name string, meth listMethod, numNeed, numAllow int) *starlark.Builtin {
-	starlarkMethod := func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	starlarkMethod := func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 		var first, second starlark.Value
 		err := starlark.UnpackArgs(b.Name(), args, nil, "first?", &first, "second?", &second)
 		if err != nil {
@@ -185,34 +377,47 @@ func NewListMethod(name string, meth listMethod, numNeed, numAllow int) *starlar
 			return starlark.None, fmt.Errorf("allows %d parameters, got %d", numAllow, len(paramList))
 		}
 		mv := b.Receiver().(*listValue)
-		return meth(mv, paramList)
+		return meth(thread, mv, paramList)
 	}
-	return starlark.NewBuiltin(name, starlarkMethod)
+	return starlark.NewBuiltin(name, guardBuiltin(starlarkMethod))
 }
 
-func listMethodAppend(lv *listValue, args []starlark.Value) (starlark.Value, error) {
-	hostItem, err := starToHost(args[0])
+func listMethodAppend(thread *starlark.Thread, lv *listValue, args []starlark.Value) (starlark.Value, error) {
+	if err := lv.checkMutable("append to"); err != nil {
+		return nil, err
+	}
+	nodeItem, err := lv.elementNode(args[0], bigIntPolicyFor(thread))
 	if err != nil {
 		return nil, err
 	}
-	lv.suffix = append(lv.suffix, hostItem.Node())
+	elem, err := elementValue(nodeItem)
+	if err != nil {
+		return nil, err
+	}
+	lv.suffix = append(lv.suffix, nodeItem)
+	lv.elems = append(lv.elems, elem)
 	return starlark.None, nil
 }
 
-func listMethodClear(lv *listValue, args []starlark.Value) (starlark.Value, error) {
+func listMethodClear(_ *starlark.Thread, lv *listValue, args []starlark.Value) (starlark.Value, error) {
+	if err := lv.checkMutable("clear"); err != nil {
+		return nil, err
+	}
 	lv.clear()
 	return starlark.None, nil
 }
 
-func listMethodCopy(lv *listValue, args []starlark.Value) (starlark.Value, error) {
+func listMethodCopy(_ *starlark.Thread, lv *listValue, args []starlark.Value) (starlark.Value, error) {
 	build := make([]datamodel.Node, len(lv.suffix))
 	for i := 0; i < len(lv.suffix); i++ {
 		build[i] = lv.suffix[i]
 	}
-	return &listValue{lv.node, build}, nil
+	elems := make([]starlark.Value, len(lv.elems))
+	copy(elems, lv.elems)
+	return &listValue{node: lv.node, suffix: build, elems: elems}, nil
 }
 
-func listMethodCount(lv *listValue, args []starlark.Value) (starlark.Value, error) {
+func listMethodCount(_ *starlark.Thread, lv *listValue, args []starlark.Value) (starlark.Value, error) {
 	var elem starlark.Value
 	err := starlark.UnpackArgs("count", args, nil, "elem", &elem)
 	if err != nil {
@@ -242,7 +447,7 @@ func listMethodCount(lv *listValue, args []starlark.Value) (starlark.Value, erro
 	return NewInt(int64(count)), nil
 }
 
-func listMethodExtend(lv *listValue, args []starlark.Value) (starlark.Value, error) {
+func listMethodExtend(thread *starlark.Thread, lv *listValue, args []starlark.Value) (starlark.Value, error) {
 	var svals starlark.Value
 	if err := starlark.UnpackPositionalArgs("extend", args, nil, 1, &svals); err != nil {
 		return starlark.None, err
@@ -252,21 +457,38 @@ func listMethodExtend(lv *listValue, args []starlark.Value) (starlark.Value, err
 	if !ok {
 		return nil, fmt.Errorf("list.extend requires an iterable")
 	}
-	starIter := siterable.Iterate()
 
+	// collect the new elements before changing anything, which
+	// also allows a list to be extended by itself
+	var nodeList []datamodel.Node
+	var elems []starlark.Value
+	starIter := siterable.Iterate()
 	var starElem starlark.Value
 	for starIter.Next(&starElem) {
-		hostItem, err := starToHost(starElem)
+		nodeItem, err := lv.elementNode(starElem, bigIntPolicyFor(thread))
+		if err == nil {
+			var elem starlark.Value
+			if elem, err = elementValue(nodeItem); err == nil {
+				nodeList = append(nodeList, nodeItem)
+				elems = append(elems, elem)
+			}
+		}
 		if err != nil {
+			starIter.Done()
 			return nil, err
 		}
-		lv.suffix = append(lv.suffix, hostItem.Node())
 	}
+	starIter.Done()
 
+	if err := lv.checkMutable("extend"); err != nil {
+		return nil, err
+	}
+	lv.suffix = append(lv.suffix, nodeList...)
+	lv.elems = append(lv.elems, elems...)
 	return starlark.None, nil
 }
 
-func listMethodIndex(lv *listValue, args []starlark.Value) (starlark.Value, error) {
+func listMethodIndex(_ *starlark.Thread, lv *listValue, args []starlark.Value) (starlark.Value, error) {
 	var elem starlark.Value
 	err := starlark.UnpackArgs("count", args, nil, "elem", &elem)
 	if err != nil {
@@ -283,7 +505,7 @@ func listMethodIndex(lv *listValue, args []starlark.Value) (starlark.Value, erro
 	return NewInt(index), nil
 }
 
-func listMethodInsert(lv *listValue, args []starlark.Value) (starlark.Value, error) {
+func listMethodInsert(thread *starlark.Thread, lv *listValue, args []starlark.Value) (starlark.Value, error) {
 	var sindex starlark.Int
 	var selem starlark.Value
 	if err := starlark.UnpackPositionalArgs("insert", args, nil, 2, &sindex, &selem); err != nil {
@@ -294,6 +516,27 @@ func listMethodInsert(lv *listValue, args []starlark.Value) (starlark.Value, err
 	if !ok {
 		return nil, fmt.Errorf("insert index invalid: %v", sindex)
 	}
+	// like starlark, negative indexes count from the end, and indexes
+	// outside of the list insert at the start or the end
+	if size := int64(lv.Len()); index < 0 {
+		index += size
+		if index < 0 {
+			index = 0
+		}
+	} else if index > size {
+		index = size
+	}
+	if err := lv.checkMutable("insert into"); err != nil {
+		return nil, err
+	}
+	nodeItem, err := lv.elementNode(selem, bigIntPolicyFor(thread))
+	if err != nil {
+		return nil, err
+	}
+	elem, err := elementValue(nodeItem)
+	if err != nil {
+		return nil, err
+	}
 
 	if index < lv.node.Length() {
 		// if index is within the already built ipld.Node, split the
@@ -309,32 +552,32 @@ func listMethodInsert(lv *listValue, args []starlark.Value) (starlark.Value, err
 
 	// going to insert by considering only the suffix slice
 	afterIndex := int(index - lv.node.Length())
-	hostItem, err := starToHost(selem)
-	if err != nil {
-		return nil, err
-	}
 
 	// rebuild the suffix, inserting the element when appropriate
 	newSuffix := make([]datamodel.Node, 0, len(lv.suffix)+1)
 	for i, nodeElem := range lv.suffix {
 		if i == afterIndex {
-			newSuffix = append(newSuffix, hostItem.Node())
+			newSuffix = append(newSuffix, nodeItem)
 		}
 		newSuffix = append(newSuffix, nodeElem)
 	}
 	if afterIndex == len(lv.suffix) {
-		newSuffix = append(newSuffix, hostItem.Node())
+		newSuffix = append(newSuffix, nodeItem)
 	}
 
 	lv.suffix = newSuffix
+	lv.elems = append(lv.elems[:index:index], append([]starlark.Value{elem}, lv.elems[index:]...)...)
 	return starlark.None, nil
 }
 
-func listMethodRemove(lv *listValue, args []starlark.Value) (starlark.Value, error) {
+func listMethodRemove(_ *starlark.Thread, lv *listValue, args []starlark.Value) (starlark.Value, error) {
 	var selem starlark.Value
 	if err := starlark.UnpackPositionalArgs("remove", args, nil, 1, &selem); err != nil {
 		return nil, err
 	}
+	if err := lv.checkMutable("remove from"); err != nil {
+		return nil, err
+	}
 	hostElem, err := starToHost(selem)
 	if err != nil {
 		return nil, err
@@ -373,10 +616,14 @@ func listMethodRemove(lv *listValue, args []starlark.Value) (starlark.Value, err
 	}
 
 	lv.suffix = newSuffix
+	lv.elems = append(lv.elems[:index:index], lv.elems[index+1:]...)
 	return starlark.None, nil
 }
 
-func listMethodReverse(lv *listValue, args []starlark.Value) (starlark.Value, error) {
+func listMethodReverse(_ *starlark.Thread, lv *listValue, args []starlark.Value) (starlark.Value, error) {
+	if err := lv.checkMutable("reverse"); err != nil {
+		return nil, err
+	}
 	// convert the entire list to a slice in order to get random access
 	_, nodeList, err := lv.splitNodeAtIndex(0)
 	if err != nil {
@@ -384,7 +631,7 @@ func listMethodReverse(lv *listValue, args []starlark.Value) (starlark.Value, er
 	}
 	nodeList = append(nodeList, lv.suffix...)
 
-	nb := basicnode.Prototype.List.NewBuilder()
+	nb := lv.node.Prototype().NewBuilder()
 	la, err := nb.BeginList(int64(len(nodeList)))
 	if err != nil {
 		return nil, err
@@ -403,10 +650,18 @@ func listMethodReverse(lv *listValue, args []starlark.Value) (starlark.Value, er
 
 	lv.node = nb.Build()
 	lv.suffix = nil
+	elems := make([]starlark.Value, len(lv.elems))
+	for i, elem := range lv.elems {
+		elems[len(elems)-1-i] = elem
+	}
+	lv.elems = elems
 	return starlark.None, nil
 }
 
-func listMethodSort(lv *listValue, args []starlark.Value) (starlark.Value, error) {
+func listMethodSort(_ *starlark.Thread, lv *listValue, args []starlark.Value) (starlark.Value, error) {
+	if err := lv.checkMutable("sort"); err != nil {
+		return nil, err
+	}
 	// convert the entire list to a slice in order to get random access
 	_, nodeList, err := lv.splitNodeAtIndex(0)
 	if err != nil {
@@ -414,18 +669,106 @@ func listMethodSort(lv *listValue, args []starlark.Value) (starlark.Value, error
 	}
 	nodeList = append(nodeList, lv.suffix...)
 
-	// sort using printed nodes
-	sort.Slice(nodeList, func(i, j int) bool {
-		return printer.Sprint(nodeList[i]) < printer.Sprint(nodeList[j])
+	// sort using printed nodes, moving the elements along with their nodes
+	order := rangeUpTo(len(nodeList))
+	sort.Slice(order, func(i, j int) bool {
+		return sprintNode(nodeList[order[i]]) < sprintNode(nodeList[order[j]])
 	})
+	sorted := make([]datamodel.Node, len(order))
+	elems := make([]starlark.Value, len(order))
+	for i, k := range order {
+		sorted[i] = nodeList[k]
+		elems[i] = lv.elems[k]
+	}
 
 	lv.clear()
-	lv.suffix = nodeList
+	lv.suffix = sorted
+	lv.elems = elems
 	return starlark.None, nil
 }
 
 // utilities
 
+// elementNode converts a starlark value into a node that can be an element of
+// the list. For a typed list, the value must match the list's value type,
+// either directly or by its representation. Ints that are too big are
+// handled by the given policy.
+func (v *listValue) elementNode(starVal starlark.Value, bigInts BigIntPolicy) (datamodel.Node, error) {
+	tp, ok := v.node.Prototype().(schema.TypedPrototype)
+	if !ok {
+		return nodeForStoring(starVal, bigInts)
+	}
+	nodeItem, err := typedListElement(tp, starVal, AnyMode, bigInts)
+	if err != nil {
+		return nil, fmt.Errorf("cannot add %v to %s: %w", starVal, v.Type(), err)
+	}
+	return nodeItem, nil
+}
+
+// typedListElement converts a starlark value into an element of the typed list.
+// It does this by building a list with just that element, so that
+// the list's own assembler does the work of checking the value type.
+func typedListElement(tp schema.TypedPrototype, starVal starlark.Value, mode Mode, bigInts BigIntPolicy) (datamodel.Node, error) {
+	var err error
+	if mode != ReprMode {
+		var node datamodel.Node
+		if node, err = buildSingleElementList(tp, starVal, bigInts); err == nil {
+			return node, nil
+		}
+	}
+	if mode != TypedMode {
+		// the value may be in the representation form of the value type
+		node, reprErr := buildSingleElementList(tp.Representation(), starVal, bigInts)
+		if reprErr == nil {
+			return node, nil
+		}
+		if err == nil {
+			err = reprErr
+		}
+	}
+	return nil, err
+}
+
+func buildSingleElementList(np datamodel.NodePrototype, starVal starlark.Value, bigInts BigIntPolicy) (datamodel.Node, error) {
+	nb := np.NewBuilder()
+	la, err := nb.BeginList(1)
+	if err != nil {
+		return nil, err
+	}
+	if err := assembleFrom(la.AssembleValue(), starVal, bigInts); err != nil {
+		return nil, err
+	}
+	if err := la.Finish(); err != nil {
+		return nil, err
+	}
+	return nb.Build().LookupByIndex(0)
+}
+
+// constructTypedList constructs a typed list, with each argument being an element
+func constructTypedList(p *Prototype, tp schema.TypedPrototype, argseq *ArgSeq) (starlark.Value, error) {
+	if argseq.names != nil {
+		return starlark.None, fmt.Errorf("cannot create %s using named arguments", p.TypeName())
+	}
+	nb := tp.NewBuilder()
+	la, err := nb.BeginList(int64(len(argseq.vals)))
+	if err != nil {
+		return starlark.None, err
+	}
+	for i, val := range argseq.vals {
+		nodeItem, err := typedListElement(tp, val, p.mode, argseq.bigInts)
+		if err != nil {
+			return starlark.None, atPath(err, strconv.Itoa(i))
+		}
+		if err := la.AssembleValue().AssignNode(nodeItem); err != nil {
+			return starlark.None, err
+		}
+	}
+	if err := la.Finish(); err != nil {
+		return starlark.None, err
+	}
+	return ToValue(nb.Build())
+}
+
 func findFirstLoc(lv *listValue, hostVal Value) (int64, error) {
 	nodeFind := hostVal.Node()
 	iter := lv.node.ListIterator()
@@ -447,7 +790,7 @@ func findFirstLoc(lv *listValue, hostVal Value) (int64, error) {
 }
 
 func (v *listValue) splitNodeAtIndex(splitIndex int64) (datamodel.Node, []datamodel.Node, error) {
-	nb := basicnode.Prototype.List.NewBuilder()
+	nb := v.node.Prototype().NewBuilder()
 	la, err := nb.BeginList(splitIndex)
 	if err != nil {
 		return nil, nil, err
@@ -481,7 +824,7 @@ func (v *listValue) applyChangesToNode() error {
 		return nil
 	}
 
-	nb := basicnode.Prototype.List.NewBuilder()
+	nb := v.node.Prototype().NewBuilder()
 	size := int(v.node.Length()) + len(v.suffix)
 	la, err := nb.BeginList(int64(size))
 	if err != nil {
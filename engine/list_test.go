@@ -1,7 +1,14 @@
 package datalarkengine
 
 import (
+	"fmt"
 	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/fluent/qp"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"go.starlark.net/starlark"
 )
 
 func TestListAppend(t *testing.T) {
@@ -477,3 +484,282 @@ list{
 }
 `)
 }
+
+func TestListIterate(t *testing.T) {
+	// iterate over elements, including appended ones
+	mustParseSchemaRunScriptAssertOutput(t,
+		`
+	`,
+		`mytypes`,
+		`
+ls = datalark.List(_=['a', 'b'])
+ls.append('c')
+def show():
+	for x in ls:
+		print(x)
+show()
+print(list(ls))
+print([x for x in ls][1:])
+print('b' in ls)
+print('z' in ls)
+print('z' not in ls)
+ls.extend(ls)
+print(len(ls))
+`, `
+string{"a"}
+string{"b"}
+string{"c"}
+[string{"a"}, string{"b"}, string{"c"}]
+[string{"b"}, string{"c"}]
+True
+False
+True
+6
+`)
+
+	// mutating while iterating is an error
+	_, err := runScript(nil, "mytypes", `
+ls = datalark.List(_=['a', 'b'])
+def f():
+	for x in ls:
+		ls.append('c')
+f()
+`)
+	qt.Assert(t, err, qt.ErrorMatches, `cannot append to list during iteration`)
+
+	_, err = runScript(nil, "mytypes", `
+ls = datalark.List(_=['a', 'b'])
+def f():
+	for x in ls:
+		ls[0] = 'z'
+f()
+`)
+	qt.Assert(t, err, qt.ErrorMatches, `cannot assign to element of list during iteration`)
+
+	// basic values are not iterable
+	_, err = runScript(nil, "mytypes", `
+def f():
+	for x in datalark.String('abc'):
+		pass
+f()
+`)
+	qt.Assert(t, err, qt.ErrorMatches, `.*not iterable`)
+}
+
+func TestTypedList(t *testing.T) {
+	schemaText := `
+		type Foo struct {
+			a String
+			b String
+		} representation stringjoin {
+			join ":"
+		}
+		type FooList [Foo]
+		type Box struct {
+			items [Foo]
+		}
+	`
+
+	// elements may be given as typed values, or by their representation
+	mustParseSchemaRunScriptAssertOutput(t, schemaText, "mytypes", `
+		ls = mytypes.FooList(mytypes.Foo(a="x", b="y"), "p:q")
+		ls.append("m:n")
+		ls[0] = {"a": "c", "b": "d"}
+		print(type(ls))
+		print(ls)
+	`, `
+		datalark.List<FooList>
+		list<FooList>{
+			0: struct<Foo>{
+				a: string<String>{"c"}
+				b: string<String>{"d"}
+			}
+			1: struct<Foo>{
+				a: string<String>{"p"}
+				b: string<String>{"q"}
+			}
+			2: struct<Foo>{
+				a: string<String>{"m"}
+				b: string<String>{"n"}
+			}
+		}
+	`)
+
+	// list type keeps its type after being rebuilt
+	mustParseSchemaRunScriptAssertOutput(t, schemaText, "mytypes", `
+		ls = mytypes.FooList("a:a", "b:b")
+		ls.insert(0, "c:c")
+		ls.reverse()
+		ls.extend(["d:d"])
+		print(type(ls))
+		print(len(ls))
+		print(ls[3].a)
+	`, `
+		datalark.List<FooList>
+		4
+		string<String>{"d"}
+	`)
+
+	// anonymous list types are shown using schema syntax
+	mustParseSchemaRunScriptAssertOutput(t, schemaText, "mytypes", `
+		box = mytypes.Box(items=["a:b"])
+		print(type(box.items))
+	`, `
+		datalark.List<[Foo]>
+	`)
+
+	// every typed value shows its type name the same way
+	mustParseSchemaRunScriptAssertOutput(t, schemaText+`
+		type Either union {
+			| Foo "foo"
+			| String "s"
+		} representation keyed
+	`, "mytypes", `
+		box = mytypes.Box(items=["a:b"])
+		print(type(box), type(box.items[0]), type(box.items[0].a))
+		print(type(mytypes.Either(s="x")))
+	`, `
+		datalark.Struct<Box> datalark.Struct<Foo> datalark.string<String>
+		datalark.Union<Either>
+	`)
+
+	defines := mustParseSchemaDefines(t, schemaText)
+	_, err := runScript(defines, "mytypes", `
+		ls = mytypes.FooList()
+		ls.append(3)
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `cannot add 3 to datalark.List<FooList>: .*`)
+
+	_, err = runScript(defines, "mytypes", `
+		ls = mytypes.FooList("a:b")
+		ls[0] = "nocolon"
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `cannot add "nocolon" to datalark.List<FooList>: .*`)
+
+	_, err = runScript(defines, "mytypes", `
+		mytypes.FooList.Typed("a:b")
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `FooList.0: .*called on a Foo node.*`)
+}
+
+func TestListFrozen(t *testing.T) {
+	val, err := NewList(starlark.NewList([]starlark.Value{starlark.String("a")}))
+	qt.Assert(t, err, qt.IsNil)
+	val.Freeze()
+
+	thread := &starlark.Thread{}
+	_, err = starlark.Call(thread, mustAttr(t, val, "append"), starlark.Tuple{starlark.String("b")}, nil)
+	qt.Assert(t, err, qt.ErrorMatches, `cannot append to frozen list`)
+	err = val.(starlark.HasSetIndex).SetIndex(0, starlark.String("b"))
+	qt.Assert(t, err, qt.ErrorMatches, `cannot assign to element of frozen list`)
+	qt.Assert(t, val.(starlark.Indexable).Len(), qt.Equals, 1)
+}
+
+func mustAttr(t *testing.T, val starlark.Value, name string) starlark.Value {
+	t.Helper()
+	attr, err := val.(starlark.HasAttrs).Attr(name)
+	qt.Assert(t, err, qt.IsNil)
+	return attr
+}
+
+func TestListIndexAndSlice(t *testing.T) {
+	mustParseSchemaRunScriptAssertOutput(t, "", "", `
+		ls = datalark.List(_=[1, 2, 3, 4, 5])
+		ls.append(6)
+		print(ls[0], ls[-1], ls[5])
+		print(type(ls[1:3]))
+		print(len(ls[1:3]), ls[1:3][0], ls[1:3][-1])
+		print([x for x in ls[::-1]])
+		print([x for x in ls[4:1:-2]])
+		print(len(ls[10:]), len(ls[3:1]))
+	`, `
+		int{1} int{6} int{6}
+		datalark.List
+		2 int{2} int{3}
+		[int{6}, int{5}, int{4}, int{3}, int{2}, int{1}]
+		[int{5}, int{3}]
+		0 0
+	`)
+
+	_, err := runScript(nil, "", `
+		ls = datalark.List(_=[1, 2])
+		ls[2]
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `datalark.List index 2 out of range \[-2:1\]`)
+
+	_, err = runScript(nil, "", `
+		ls = datalark.List(_=[])
+		ls[-1]
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `index -1 out of range: empty datalark.List`)
+
+	// slicing a typed list keeps its type
+	mustParseSchemaRunScriptAssertOutput(t, `
+		type FooList [String]
+	`, "mytypes", `
+		ls = mytypes.FooList("a", "b", "c")
+		ls.append("d")
+		part = ls[1::2]
+		print(type(part))
+		print(part[0], part[1])
+		part.append("e")
+		print(len(part), len(ls))
+	`, `
+		datalark.List<FooList>
+		string<String>{"b"} string<String>{"d"}
+		3 4
+	`)
+}
+
+// unreadableList is a list node whose element at index 1 can't be read, like
+// a node backed by storage that fails partway through
+type unreadableList struct {
+	datamodel.Node
+}
+
+func (n unreadableList) ListIterator() datamodel.ListIterator {
+	return &unreadableListIterator{n.Node.ListIterator()}
+}
+
+type unreadableListIterator struct {
+	datamodel.ListIterator
+}
+
+func (it *unreadableListIterator) Next() (int64, datamodel.Node, error) {
+	idx, item, err := it.ListIterator.Next()
+	if idx == 1 {
+		return idx, nil, fmt.Errorf("element is unreadable")
+	}
+	return idx, item, err
+}
+
+func TestListUnreadableElement(t *testing.T) {
+	inner, err := qp.BuildList(basicnode.Prototype.Any, 2, func(la datamodel.ListAssembler) {
+		qp.ListEntry(la, qp.Int(1))
+		qp.ListEntry(la, qp.Int(2))
+	})
+	qt.Assert(t, err, qt.IsNil)
+
+	// the error is found when the value is made, rather than when indexing
+	// or iterating hides it as None or as the end of the list
+	_, err = ToValue(unreadableList{inner})
+	qt.Assert(t, err, qt.ErrorMatches, `1: element is unreadable`)
+
+	outer, err := qp.BuildList(basicnode.Prototype.Any, 1, func(la datamodel.ListAssembler) {
+		qp.ListEntry(la, qp.Node(unreadableList{inner}))
+	})
+	qt.Assert(t, err, qt.IsNil)
+	_, err = ToValue(outer)
+	qt.Assert(t, err, qt.ErrorMatches, `0\.1: element is unreadable`)
+
+	// values of a map are read when they are looked up, which can
+	// return the error
+	m, err := qp.BuildMap(basicnode.Prototype.Any, 1, func(ma datamodel.MapAssembler) {
+		qp.MapEntry(ma, "a", qp.Node(unreadableList{inner}))
+	})
+	qt.Assert(t, err, qt.IsNil)
+	val, err := ToValue(m)
+	qt.Assert(t, err, qt.IsNil)
+	_, _, err = val.(starlark.Mapping).Get(starlark.String("a"))
+	qt.Assert(t, err, qt.ErrorMatches, `1: element is unreadable`)
+}
@@ -1,36 +1,79 @@
 package datalarkengine
 
 import (
-	"errors"
 	"fmt"
+	"strconv"
 
 	ipldmodel "github.com/ipld/go-ipld-prime/datamodel"
 	"github.com/ipld/go-ipld-prime/node/basicnode"
-	"github.com/ipld/go-ipld-prime/printer"
 	"github.com/ipld/go-ipld-prime/schema"
 	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
 )
 
+// mapValue is a map, along with changes that haven't been applied to its node
+// yet. The changes are tracked using the string form of each key (see mapKeyString),
+// so that keys of any type can be compared.
+//
+// Since iterating over a map can't return errors, every key is converted to
+// a value when it enters the map, and kept in keys by its string form;
+// nodeNames holds the string forms of the node's own keys, in order.
 type mapValue struct {
-	node     ipldmodel.Node
-	add      map[string]ipldmodel.Node
-	addNames []string
-	del      map[string]struct{}
-	replace  map[string]ipldmodel.Node
+	node      ipldmodel.Node
+	nodeNames []string
+	keys      map[string]starlark.Value
+	add       map[string]ipldmodel.Node
+	addNames  []string
+	del       map[string]struct{}
+	replace   map[string]ipldmodel.Node
+	// itercount is the number of active iterators, while
+	// there are any, the map must not be mutated
+	itercount uint32
+	frozen    bool
 }
 
 // compile-time interface assertions
 var (
-	_ Value              = (*mapValue)(nil)
-	_ starlark.Value     = (*mapValue)(nil)
-	_ starlark.Mapping   = (*mapValue)(nil)
-	_ starlark.Sequence  = (*mapValue)(nil)
-	_ starlark.HasSetKey = (*mapValue)(nil)
-	_ starlark.HasAttrs  = (*mapValue)(nil)
+	_ Value               = (*mapValue)(nil)
+	_ starlark.Value      = (*mapValue)(nil)
+	_ starlark.Mapping    = (*mapValue)(nil)
+	_ starlark.Sequence   = (*mapValue)(nil)
+	_ starlark.HasSetKey  = (*mapValue)(nil)
+	_ starlark.HasAttrs   = (*mapValue)(nil)
+	_ starlark.Comparable = (*mapValue)(nil)
 )
 
-func newMapValue(node ipldmodel.Node) Value {
-	return &mapValue{node, nil, nil, nil, nil}
+func newMapValue(node ipldmodel.Node) (Value, error) {
+	v := &mapValue{node: node}
+	if err := v.loadKeys(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// loadKeys converts the keys of the map's node, replacing any that were
+// loaded before
+func (v *mapValue) loadKeys() error {
+	v.nodeNames = make([]string, 0, v.node.Length())
+	v.keys = make(map[string]starlark.Value, v.node.Length())
+	nodeMapIter := v.node.MapIterator()
+	for nodeMapIter != nil && !nodeMapIter.Done() {
+		nkey, _, err := nodeMapIter.Next()
+		if err != nil {
+			return err
+		}
+		name, err := mapKeyString(nkey)
+		if err != nil {
+			return err
+		}
+		key, err := elementValue(nkey)
+		if err != nil {
+			return atPath(err, name)
+		}
+		v.nodeNames = append(v.nodeNames, name)
+		v.keys[name] = key
+	}
+	return nil
 }
 
 func (v *mapValue) Node() ipldmodel.Node {
@@ -39,20 +82,31 @@ func (v *mapValue) Node() ipldmodel.Node {
 }
 func (v *mapValue) Type() string {
 	if tn, ok := v.node.(schema.TypedNode); ok {
-		return fmt.Sprintf("datalark.Map<%T>", tn.Type().Name())
+		return fmt.Sprintf("datalark.Map<%s>", typeDisplayName(tn.Type()))
 	}
 	return fmt.Sprintf("datalark.Map")
 }
 func (v *mapValue) String() string {
 	v.applyChangesToNode()
-	return printer.Sprint(v.node)
+	return sprintNode(v.node)
+}
+func (v *mapValue) Freeze() {
+	v.frozen = true
 }
-func (v *mapValue) Freeze() {}
 func (v *mapValue) Truth() starlark.Bool {
 	return true
 }
+
+// Hash returns an error, because like starlark dicts, maps are not hashable,
+// even once frozen.
 func (v *mapValue) Hash() (uint32, error) {
-	return 0, errors.New("TODO")
+	return 0, fmt.Errorf("unhashable type: %s", v.Type())
+}
+
+// CompareSameType compares the contents of maps, which can only be
+// checked for equality, like starlark dicts
+func (v *mapValue) CompareSameType(op syntax.Token, y starlark.Value, depth int) (bool, error) {
+	return compareNodesEqual(op, v, y.(Value))
 }
 
 // Get returns a value from a map, implementing starlark.Mapping
@@ -62,11 +116,14 @@ func (v *mapValue) Hash() (uint32, error) {
 //   d['a'] # calls d.Get('a')
 //
 func (v *mapValue) Get(in starlark.Value) (out starlark.Value, found bool, err error) {
-	skey, ok := in.(starlark.String)
-	if !ok {
-		return starlark.None, false, fmt.Errorf("cannot index map using %v of type %T", in, in)
+	nkey, err := v.keyNode(in)
+	if err != nil {
+		return starlark.None, false, err
+	}
+	name, err := mapKeyString(nkey)
+	if err != nil {
+		return starlark.None, false, err
 	}
-	name := string(skey)
 
 	// if key has been deleted, return nil early
 	if _, ok := v.del[name]; ok {
@@ -74,24 +131,52 @@ func (v *mapValue) Get(in starlark.Value) (out starlark.Value, found bool, err e
 	}
 
 	// look in add, replace first
-	if nval, ok := v.add[name]; ok {
-		return nodeToHost(nval), true, nil
+	nval, ok := v.add[name]
+	if !ok {
+		nval, ok = v.replace[name]
 	}
-	if nval, ok := v.replace[name]; ok {
-		return nodeToHost(nval), true, nil
+	if !ok {
+		// look in the ipld node
+		nval, err = v.lookupNode(name)
+		if err != nil || nval == nil {
+			return nil, false, err
+		}
 	}
-	// look in the ipld node
-	nval, err := v.node.LookupByString(name)
+	val, err := ToValue(nval)
 	if err != nil {
 		return nil, false, err
 	}
-	return nodeToHost(nval), true, err
+	return val, true, nil
 }
 
 // starlark.Sequence
 
+// Iterate returns an iterator over the keys of the map, including pending
+// changes. While any iterator is active, the map cannot be mutated.
 func (v *mapValue) Iterate() starlark.Iterator {
-	panic(fmt.Errorf("TODO(dustmop): mapValue.Iterate not implemented for %T", v))
+	v.itercount++
+	return &mapIterator{mv: v, names: v.keyNames()}
+}
+
+// mapIterator goes over the keys the map had when the iteration started,
+// which were already converted to values, so that Next can't fail
+type mapIterator struct {
+	mv    *mapValue
+	names []string
+	index int
+}
+
+func (it *mapIterator) Next(p *starlark.Value) bool {
+	if it.index < len(it.names) {
+		*p = it.mv.keys[it.names[it.index]]
+		it.index++
+		return true
+	}
+	return false
+}
+
+func (it *mapIterator) Done() {
+	it.mv.itercount--
 }
 
 func (v *mapValue) Len() int {
@@ -100,26 +185,53 @@ func (v *mapValue) Len() int {
 
 // utility methods
 
+// keyNames returns the string form of each key in the map, in order,
+// including pending changes
+func (v *mapValue) keyNames() []string {
+	names := make([]string, 0, v.Len())
+	for _, name := range v.nodeNames {
+		if _, ok := v.del[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	return append(names, v.addNames...)
+}
+
+// checkMutable returns an error if the map cannot be modified right now,
+// which is the case once it is frozen, or while it is being iterated
+func (v *mapValue) checkMutable(verb string) error {
+	if v.frozen {
+		return fmt.Errorf("cannot %s frozen map", verb)
+	}
+	if v.itercount > 0 {
+		return fmt.Errorf("cannot %s map during iteration", verb)
+	}
+	return nil
+}
+
 func (v *mapValue) clear() {
 	nb := v.node.Prototype().NewBuilder()
 	ma, _ := nb.BeginMap(0)
 	_ = ma.Finish()
 	v.node = nb.Build()
+	v.nodeNames = nil
+	v.keys = nil
 	v.add = nil
 	v.addNames = nil
 	v.replace = nil
 	v.del = nil
 }
 
-func (v *mapValue) removeKey(skey starlark.String) starlark.Value {
-	name := string(skey)
-
+// removeKey removes the key, which is given in its string form, returning
+// the value that it had, or nil if the key wasn't in the map
+func (v *mapValue) removeKey(name string) (starlark.Value, error) {
 	if v.add != nil {
 		if node, ok := v.add[name]; ok {
 			// if key had been added, remove from the add map
 			delete(v.add, name)
+			delete(v.keys, name)
 			v.addNames = removeFromSlice(v.addNames, name)
-			return nodeToHost(node)
+			return ToValue(node)
 		}
 	}
 	if v.replace != nil {
@@ -130,29 +242,28 @@ func (v *mapValue) removeKey(skey starlark.String) starlark.Value {
 				v.del = make(map[string]struct{})
 			}
 			v.del[name] = struct{}{}
-			return nodeToHost(node)
+			return ToValue(node)
 		}
 	}
 	if v.del != nil {
 		if _, ok := v.del[name]; ok {
 			// if key had been deleted, do nothing, just return
-			return nil
+			return nil, nil
 		}
 	}
 
-	sval, found, _ := v.Get(skey)
-	if found {
+	nval, _ := v.lookupNode(name)
+	if nval != nil {
 		// remove the key by marking it as deleted
-		name := string(skey)
 		if v.del == nil {
 			v.del = make(map[string]struct{})
 		}
 		v.del[name] = struct{}{}
-		return sval
+		return ToValue(nval)
 	}
 
 	// key not found, return nil and let caller handle it
-	return nil
+	return nil, nil
 }
 
 func (v *mapValue) lastInsertedKey() (string, bool) {
@@ -168,10 +279,13 @@ func (v *mapValue) lastInsertedKey() (string, bool) {
 		if err != nil {
 			continue
 		}
-		name, err := nkey.AsString()
+		name, err := mapKeyString(nkey)
 		if err != nil {
 			continue
 		}
+		if _, ok := v.del[name]; ok {
+			continue
+		}
 		lastKey = name
 		hasKey = true
 	}
@@ -181,7 +295,7 @@ func (v *mapValue) lastInsertedKey() (string, bool) {
 
 // starlark.HasAttrs : starlark.Map
 
-type mapMethod func(*mapValue, []starlark.Value) (starlark.Value, error)
+type mapMethod func(*starlark.Thread, *mapValue, []starlark.Value) (starlark.Value, error)
 
 var mapMethods = map[string]*starlark.Builtin{
 	"clear":      NewMapMethod("clear", mapMethodClear, 0, 0),
@@ -195,10 +309,11 @@ var mapMethods = map[string]*starlark.Builtin{
 	"setdefault": NewMapMethod("setdefault", mapMethodSetdefault, 1, 2),
 	"update":     NewMapMethod("update", mapMethodUpdate, 1, 1),
 	"values":     NewMapMethod("values", mapMethodValues, 0, 0),
+	"with_path":  NewMapMethod("with_path", mapMethodWithPath, 2, 2),
 }
 
 func NewMapMethod(name string, meth mapMethod, numNeed, numAllow int) *starlark.Builtin {
-	starlarkMethod := func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	starlarkMethod := func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 		var first, second starlark.Value
 		err := starlark.UnpackArgs(b.Name(), args, nil, "first?", &first, "second?", &second)
 		if err != nil {
@@ -218,24 +333,32 @@ func NewMapMethod(name string, meth mapMethod, numNeed, numAllow int) *starlark.
 			return starlark.None, fmt.Errorf("allows %d parameters, got %d", numAllow, len(paramList))
 		}
 		mv := b.Receiver().(*mapValue)
-		return meth(mv, paramList)
+		return meth(thread, mv, paramList)
 	}
-	return starlark.NewBuiltin(name, starlarkMethod)
+	return starlark.NewBuiltin(name, guardBuiltin(starlarkMethod))
 }
 
-func mapMethodClear(mv *mapValue, args []starlark.Value) (starlark.Value, error) {
+func mapMethodClear(_ *starlark.Thread, mv *mapValue, args []starlark.Value) (starlark.Value, error) {
+	if err := mv.checkMutable("clear"); err != nil {
+		return starlark.None, err
+	}
 	mv.clear()
 	return starlark.None, nil
 }
 
-func mapMethodCopy(mv *mapValue, args []starlark.Value) (starlark.Value, error) {
+func mapMethodCopy(_ *starlark.Thread, mv *mapValue, args []starlark.Value) (starlark.Value, error) {
 	build := &mapValue{}
 	build.node = mv.node
+	build.nodeNames = mv.nodeNames
+	build.keys = make(map[string]starlark.Value, len(mv.keys))
+	for name, key := range mv.keys {
+		build.keys[name] = key
+	}
 	if mv.add != nil {
 		build.add = make(map[string]ipldmodel.Node, len(mv.add))
 		build.addNames = make([]string, 0, len(mv.addNames))
-		for name, nval := range mv.add {
-			build.add[name] = nval
+		for _, name := range mv.addNames {
+			build.add[name] = mv.add[name]
 			build.addNames = append(build.addNames, name)
 		}
 	}
@@ -254,7 +377,7 @@ func mapMethodCopy(mv *mapValue, args []starlark.Value) (starlark.Value, error)
 	return build, nil
 }
 
-func mapMethodFromkeys(mv *mapValue, args []starlark.Value) (starlark.Value, error) {
+func mapMethodFromkeys(_ *starlark.Thread, mv *mapValue, args []starlark.Value) (starlark.Value, error) {
 	var skeys, svalue starlark.Value
 	if err := starlark.UnpackPositionalArgs("fromkeys", args, nil, 1, &skeys, &svalue); err != nil {
 		return starlark.None, err
@@ -287,13 +410,13 @@ func mapMethodFromkeys(mv *mapValue, args []starlark.Value) (starlark.Value, err
 
 	var skey starlark.Value
 	for starIter.Next(&skey) {
-		key, ok := starlark.AsString(skey)
-		if !ok {
-			return nil, fmt.Errorf("could not convert key to string: %v", skey)
+		nkey, err := mv.keyNode(skey)
+		if err != nil {
+			return nil, err
 		}
 		// construct each key value pair in the new map
 		na := ma.AssembleKey()
-		if err = na.AssignString(key); err != nil {
+		if err = na.AssignNode(nkey); err != nil {
 			return nil, err
 		}
 		na = ma.AssembleValue()
@@ -305,10 +428,10 @@ func mapMethodFromkeys(mv *mapValue, args []starlark.Value) (starlark.Value, err
 	if err != nil {
 		return nil, err
 	}
-	return newMapValue(nb.Build()), nil
+	return newMapValue(nb.Build())
 }
 
-func mapMethodGet(mv *mapValue, args []starlark.Value) (starlark.Value, error) {
+func mapMethodGet(_ *starlark.Thread, mv *mapValue, args []starlark.Value) (starlark.Value, error) {
 	var skey, sdefault starlark.Value
 	if err := starlark.UnpackPositionalArgs("get", args, nil, 1, &skey, &sdefault); err != nil {
 		return starlark.None, err
@@ -325,7 +448,7 @@ func mapMethodGet(mv *mapValue, args []starlark.Value) (starlark.Value, error) {
 	return starlark.None, nil
 }
 
-func mapMethodItems(mv *mapValue, args []starlark.Value) (starlark.Value, error) {
+func mapMethodItems(_ *starlark.Thread, mv *mapValue, args []starlark.Value) (starlark.Value, error) {
 	var hostItems []starlark.Value
 	var err error
 
@@ -336,7 +459,7 @@ func mapMethodItems(mv *mapValue, args []starlark.Value) (starlark.Value, error)
 		if err != nil {
 			return starlark.None, err
 		}
-		name, err := nkey.AsString()
+		name, err := mapKeyString(nkey)
 		if err != nil {
 			return starlark.None, err
 		}
@@ -360,7 +483,7 @@ func mapMethodItems(mv *mapValue, args []starlark.Value) (starlark.Value, error)
 	// add new keys and values to the new builder
 	for _, name := range mv.addNames {
 		nval := mv.add[name]
-		hostItems, err = appendTwoItemListAsHost(hostItems, basicnode.NewString(name), nval)
+		hostItems, err = appendTwoItemListAsHost(hostItems, mv.keys[name].(Value).Node(), nval)
 		if err != nil {
 			return starlark.None, err
 		}
@@ -369,7 +492,7 @@ func mapMethodItems(mv *mapValue, args []starlark.Value) (starlark.Value, error)
 	return NewList(starlark.NewList(hostItems))
 }
 
-func mapMethodKeys(mv *mapValue, args []starlark.Value) (starlark.Value, error) {
+func mapMethodKeys(_ *starlark.Thread, mv *mapValue, args []starlark.Value) (starlark.Value, error) {
 	var hostItems []starlark.Value
 
 	nodeMapIter := mv.node.MapIterator()
@@ -378,32 +501,50 @@ func mapMethodKeys(mv *mapValue, args []starlark.Value) (starlark.Value, error)
 		if err != nil {
 			return starlark.None, err
 		}
-		name, err := nkey.AsString()
+		name, err := mapKeyString(nkey)
 		if err != nil {
 			return starlark.None, err
 		}
 		if _, ok := mv.del[name]; ok {
 			continue
 		}
-		hostItems = append(hostItems, nodeToHost(nkey))
+		if hostItems, err = appendAsHost(hostItems, nkey); err != nil {
+			return starlark.None, err
+		}
 	}
 
 	// add new keys and values to the new builder
 	for _, name := range mv.addNames {
-		hostItems = append(hostItems, nodeToHost(basicnode.NewString(name)))
+		var err error
+		if hostItems, err = appendAsHost(hostItems, mv.keys[name].(Value).Node()); err != nil {
+			return starlark.None, err
+		}
 	}
 
 	// return as a datalark.Value(*datalark.List) with starlark.Value interface
 	return NewList(starlark.NewList(hostItems))
 }
 
-func mapMethodPop(mv *mapValue, args []starlark.Value) (starlark.Value, error) {
-	var skey starlark.String
-	var sdefault starlark.Value
+func mapMethodPop(_ *starlark.Thread, mv *mapValue, args []starlark.Value) (starlark.Value, error) {
+	var skey, sdefault starlark.Value
 	if err := starlark.UnpackPositionalArgs("pop", args, nil, 1, &skey, &sdefault); err != nil {
 		return starlark.None, err
 	}
-	sval := mv.removeKey(skey)
+	if err := mv.checkMutable("delete from"); err != nil {
+		return starlark.None, err
+	}
+	nkey, err := mv.keyNode(skey)
+	if err != nil {
+		return starlark.None, err
+	}
+	name, err := mapKeyString(nkey)
+	if err != nil {
+		return starlark.None, err
+	}
+	sval, err := mv.removeKey(name)
+	if err != nil {
+		return starlark.None, err
+	}
 	if sval != nil {
 		return sval, nil
 	}
@@ -413,17 +554,19 @@ func mapMethodPop(mv *mapValue, args []starlark.Value) (starlark.Value, error) {
 	return nil, fmt.Errorf("error, not found: %s", skey)
 }
 
-func mapMethodPopitem(mv *mapValue, args []starlark.Value) (starlark.Value, error) {
+func mapMethodPopitem(_ *starlark.Thread, mv *mapValue, args []starlark.Value) (starlark.Value, error) {
+	if err := mv.checkMutable("delete from"); err != nil {
+		return starlark.None, err
+	}
 	name, hasKey := mv.lastInsertedKey()
 	if !hasKey {
 		return starlark.None, fmt.Errorf("error, not found: %s", name)
 	}
-	return mv.removeKey(starlark.String(name)), nil
+	return mv.removeKey(name)
 }
 
-func mapMethodSetdefault(mv *mapValue, args []starlark.Value) (starlark.Value, error) {
-	var skey starlark.String
-	var svalue starlark.Value
+func mapMethodSetdefault(thread *starlark.Thread, mv *mapValue, args []starlark.Value) (starlark.Value, error) {
+	var skey, svalue starlark.Value
 	if err := starlark.UnpackPositionalArgs("setdefault", args, nil, 1, &skey, &svalue); err != nil {
 		return starlark.None, err
 	}
@@ -437,15 +580,16 @@ func mapMethodSetdefault(mv *mapValue, args []starlark.Value) (starlark.Value, e
 		svalue = starlark.None
 	}
 	// insert the default value
-	err = mv.SetKey(skey, svalue)
+	err = mv.setKey(skey, svalue, bigIntPolicyFor(thread))
 	if err != nil {
 		return starlark.None, err
 	}
-	// return it
-	return starToHost(svalue)
+	// return it, as it was stored in the map
+	sval, _, err = mv.Get(skey)
+	return sval, err
 }
 
-func mapMethodUpdate(mv *mapValue, args []starlark.Value) (starlark.Value, error) {
+func mapMethodUpdate(thread *starlark.Thread, mv *mapValue, args []starlark.Value) (starlark.Value, error) {
 	starObj, ok := args[0].(starlark.IterableMapping)
 	if !ok {
 		return nil, fmt.Errorf("map.update requires an iterable mapping")
@@ -460,7 +604,7 @@ func mapMethodUpdate(mv *mapValue, args []starlark.Value) (starlark.Value, error
 		if err != nil {
 			return nil, err
 		}
-		err = mv.SetKey(skey, sval)
+		err = mv.setKey(skey, sval, bigIntPolicyFor(thread))
 		if err != nil {
 			return nil, err
 		}
@@ -469,7 +613,7 @@ func mapMethodUpdate(mv *mapValue, args []starlark.Value) (starlark.Value, error
 	return starlark.None, nil
 }
 
-func mapMethodValues(mv *mapValue, args []starlark.Value) (starlark.Value, error) {
+func mapMethodValues(_ *starlark.Thread, mv *mapValue, args []starlark.Value) (starlark.Value, error) {
 	// all content should be datalark.Node, but using a starlark.Value interface
 	var hostItems []starlark.Value
 
@@ -480,7 +624,7 @@ func mapMethodValues(mv *mapValue, args []starlark.Value) (starlark.Value, error
 		if err != nil {
 			return starlark.None, err
 		}
-		name, err := nkey.AsString()
+		name, err := mapKeyString(nkey)
 		if err != nil {
 			return starlark.None, err
 		}
@@ -491,16 +635,19 @@ func mapMethodValues(mv *mapValue, args []starlark.Value) (starlark.Value, error
 		}
 		// if the value has been replaced, use the replacement
 		if nodeReplace, ok := mv.replace[name]; ok {
-			hostItems = append(hostItems, nodeToHost(nodeReplace))
-			continue
+			nval = nodeReplace
+		}
+		if hostItems, err = appendAsHost(hostItems, nval); err != nil {
+			return starlark.None, err
 		}
-		hostItems = append(hostItems, nodeToHost(nval))
 	}
 
 	// add new keys and values to the new builder
 	for _, name := range mv.addNames {
-		nodeAdd := mv.add[name]
-		hostItems = append(hostItems, nodeToHost(nodeAdd))
+		var err error
+		if hostItems, err = appendAsHost(hostItems, mv.add[name]); err != nil {
+			return starlark.None, err
+		}
 	}
 
 	// return as a datalark.Value(*datalark.List) with starlark.Value interface
@@ -525,16 +672,30 @@ func (v *mapValue) AttrNames() []string {
 
 // starlark.HasSetKey
 
-// SetKey assigns a value to a map at the given key
+// SetKey assigns a value to a map at the given key. Starlark doesn't give
+// it the thread, so ints that are too big are always an error
 func (v *mapValue) SetKey(starName, starVal starlark.Value) error {
-	hval, err := starToHost(starVal)
+	return v.setKey(starName, starVal, BigIntError)
+}
+
+// setKey assigns a value to a map at the given key, handling ints that are
+// too big by the given policy
+func (v *mapValue) setKey(starName, starVal starlark.Value, bigInts BigIntPolicy) error {
+	if err := v.checkMutable("insert into"); err != nil {
+		return err
+	}
+	nkey, err := v.keyNode(starName)
+	if err != nil {
+		return err
+	}
+	name, err := mapKeyString(nkey)
+	if err != nil {
+		return err
+	}
+	node, err := v.valueNode(nkey, starVal, bigInts)
 	if err != nil {
 		return err
 	}
-	node := hval.Node()
-
-	var name string
-	name, _ = starlark.AsString(starName)
 
 	if v.add != nil {
 		if _, ok := v.add[name]; ok {
@@ -554,12 +715,20 @@ func (v *mapValue) SetKey(starName, starVal starlark.Value) error {
 		}
 	}
 
-	exist, _ := v.node.LookupByString(name)
+	exist, _ := v.lookupNode(name)
 	if exist == nil {
+		key, err := elementValue(nkey)
+		if err != nil {
+			return err
+		}
 		if v.add == nil {
 			v.add = make(map[string]ipldmodel.Node)
 		}
+		if v.keys == nil {
+			v.keys = make(map[string]starlark.Value)
+		}
 		v.add[name] = node
+		v.keys[name] = key
 		v.addNames = append(v.addNames, name)
 	} else {
 		if v.replace == nil {
@@ -592,7 +761,7 @@ func (v *mapValue) applyChangesToNode() error {
 		if err != nil {
 			return err
 		}
-		name, err := nkey.AsString()
+		name, err := mapKeyString(nkey)
 		if err != nil {
 			return err
 		}
@@ -602,9 +771,9 @@ func (v *mapValue) applyChangesToNode() error {
 			continue
 		}
 
-		// assign the string key to the new builder
+		// assign the key to the new builder
 		na := ma.AssembleKey()
-		if err = na.AssignString(name); err != nil {
+		if err = na.AssignNode(nkey); err != nil {
 			return err
 		}
 		if nodeReplace, ok := v.replace[name]; ok {
@@ -626,12 +795,12 @@ func (v *mapValue) applyChangesToNode() error {
 	for _, name := range v.addNames {
 		nodeAdd := v.add[name]
 		na := ma.AssembleKey()
-		if err = na.AssignString(name); err != nil {
-			return nil
+		if err = na.AssignNode(v.keys[name].(Value).Node()); err != nil {
+			return err
 		}
 		na = ma.AssembleValue()
 		if err = na.AssignNode(nodeAdd); err != nil {
-			return nil
+			return err
 		}
 	}
 
@@ -641,6 +810,10 @@ func (v *mapValue) applyChangesToNode() error {
 		return err
 	}
 	v.node = nb.Build()
+	v.nodeNames = v.keyNames()
+	for name := range v.del {
+		delete(v.keys, name)
+	}
 	v.add = nil
 	v.addNames = nil
 	v.replace = nil
@@ -648,9 +821,251 @@ func (v *mapValue) applyChangesToNode() error {
 	return nil
 }
 
+// keyNode converts a starlark value into a key for the map. For a typed map,
+// the value must match the map's key type, either directly or by its
+// representation, such as the string form of a stringjoin struct.
+func (v *mapValue) keyNode(skey starlark.Value) (ipldmodel.Node, error) {
+	if tp, ok := v.node.Prototype().(schema.TypedPrototype); ok {
+		if _, ok := tp.Type().(*schema.TypeMap); ok {
+			nkey, err := typedMapKey(tp, skey, AnyMode)
+			if err != nil {
+				return nil, fmt.Errorf("cannot use %v as a key of %s: %w", skey, v.Type(), err)
+			}
+			return nkey, nil
+		}
+	}
+	name, err := mapKeyName(skey)
+	if err != nil {
+		return nil, err
+	}
+	return basicnode.NewString(name), nil
+}
+
+// valueNode converts a starlark value into a node that can be a value in the
+// map. For a typed map, the value must match the map's value type, either
+// directly or by its representation. Ints that are too big are handled by
+// the given policy.
+func (v *mapValue) valueNode(nkey ipldmodel.Node, starVal starlark.Value, bigInts BigIntPolicy) (ipldmodel.Node, error) {
+	tp, ok := v.node.Prototype().(schema.TypedPrototype)
+	if !ok {
+		return nodeForStoring(starVal, bigInts)
+	}
+	node, err := typedMapValue(tp, nkey, starVal, AnyMode, bigInts)
+	if err != nil {
+		return nil, fmt.Errorf("cannot assign %v to %s: %w", starVal, v.Type(), err)
+	}
+	return node, nil
+}
+
+// lookupNode finds the value of a key, given in its string form, in the map's
+// node. Returns nil if there is no such key.
+func (v *mapValue) lookupNode(name string) (ipldmodel.Node, error) {
+	nval, err := v.node.LookupByString(name)
+	if err == nil {
+		return nval, nil
+	}
+	if _, ok := err.(ipldmodel.ErrNotExists); ok {
+		return nil, nil
+	}
+	// keys that aren't represented as strings, such as ints, can't
+	// be looked up by string, so search for the key instead
+	nodeMapIter := v.node.MapIterator()
+	for nodeMapIter != nil && !nodeMapIter.Done() {
+		nkey, nval, err := nodeMapIter.Next()
+		if err != nil {
+			return nil, err
+		}
+		if keyName, err := mapKeyString(nkey); err == nil && keyName == name {
+			return nval, nil
+		}
+	}
+	return nil, nil
+}
+
+// typedMapKey converts a starlark value into a key for the typed map
+func typedMapKey(tp schema.TypedPrototype, skey starlark.Value, mode Mode) (ipldmodel.Node, error) {
+	ma, err := tp.NewBuilder().BeginMap(1)
+	if err != nil {
+		return nil, err
+	}
+	kp := ma.KeyPrototype()
+	ktp, ok := kp.(schema.TypedPrototype)
+	if !ok {
+		nb := kp.NewBuilder()
+		if err := assembleFrom(nb, skey, BigIntError); err != nil {
+			return nil, err
+		}
+		return nb.Build(), nil
+	}
+
+	// enums have their own rules for which values are members
+	if typ, ok := ktp.Type().(*schema.TypeEnum); ok {
+		p := &Prototype{name: typ.Name(), np: ktp, mode: mode}
+		val, err := constructEnumValue(p, ktp, typ, &ArgSeq{vals: []starlark.Value{skey}, scalar: true})
+		if err != nil {
+			return nil, err
+		}
+		return val.(Value).Node(), nil
+	}
+
+	if mode != ReprMode {
+		nb := ktp.NewBuilder()
+		if err = assembleFrom(nb, skey, BigIntError); err == nil {
+			return nb.Build(), nil
+		}
+	}
+	if mode != TypedMode {
+		// the key may be in its representation form
+		nb := ktp.Representation().NewBuilder()
+		reprErr := assembleFrom(nb, skey, BigIntError)
+		if reprErr == nil {
+			return nb.Build(), nil
+		}
+		if err == nil {
+			err = reprErr
+		}
+	}
+	return nil, err
+}
+
+// typedMapValue converts a starlark value into a value for the typed map.
+// It does this by building a map with just that entry, so that the map's
+// own assembler does the work of checking the value type.
+func typedMapValue(tp schema.TypedPrototype, nkey ipldmodel.Node, starVal starlark.Value, mode Mode, bigInts BigIntPolicy) (ipldmodel.Node, error) {
+	var err error
+	if mode != ReprMode {
+		var node ipldmodel.Node
+		if node, err = buildSingleEntryMap(tp, nkey, starVal, bigInts); err == nil {
+			return node, nil
+		}
+	}
+	if mode != TypedMode {
+		// the value may be in the representation form of the value type,
+		// in which case the key has to be in its representation form too
+		reprKey := nkey
+		if tn, ok := nkey.(schema.TypedNode); ok {
+			reprKey = tn.Representation()
+		}
+		node, reprErr := buildSingleEntryMap(tp.Representation(), reprKey, starVal, bigInts)
+		if reprErr == nil {
+			return node, nil
+		}
+		if err == nil {
+			err = reprErr
+		}
+	}
+	return nil, err
+}
+
+func buildSingleEntryMap(np ipldmodel.NodePrototype, nkey ipldmodel.Node, starVal starlark.Value, bigInts BigIntPolicy) (ipldmodel.Node, error) {
+	nb := np.NewBuilder()
+	ma, err := nb.BeginMap(1)
+	if err != nil {
+		return nil, err
+	}
+	if err := ma.AssembleKey().AssignNode(nkey); err != nil {
+		return nil, err
+	}
+	if err := assembleFrom(ma.AssembleValue(), starVal, bigInts); err != nil {
+		return nil, err
+	}
+	if err := ma.Finish(); err != nil {
+		return nil, err
+	}
+	_, nval, err := nb.Build().MapIterator().Next()
+	return nval, err
+}
+
+// constructTypedMap constructs a typed map from the keys and values of the args
+func constructTypedMap(p *Prototype, tp schema.TypedPrototype, argseq *ArgSeq) (starlark.Value, error) {
+	// maybe a single string, for maps with a string representation
+	if p.mode != TypedMode && argseq.IsSingleString() {
+		if val, err := constructFromStringRepresentation(tp, argseq); err == nil {
+			return val, nil
+		}
+	}
+	if argseq.keys == nil && len(argseq.vals) > 0 {
+		return starlark.None, fmt.Errorf("cannot create %s without keys, use kwargs or restructuring", p.TypeName())
+	}
+
+	nb := tp.NewBuilder()
+	ma, err := nb.BeginMap(int64(len(argseq.vals)))
+	if err != nil {
+		return starlark.None, err
+	}
+	for i, skey := range argseq.keys {
+		nkey, err := typedMapKey(tp, skey, p.mode)
+		if err != nil {
+			return starlark.None, fmt.Errorf("cannot create %s with key %v: %w", p.TypeName(), skey, err)
+		}
+		nval, err := typedMapValue(tp, nkey, argseq.vals[i], p.mode, argseq.bigInts)
+		if err != nil {
+			return starlark.None, atPath(err, asString(skey))
+		}
+		if err := ma.AssembleKey().AssignNode(nkey); err != nil {
+			return starlark.None, err
+		}
+		if err := ma.AssembleValue().AssignNode(nval); err != nil {
+			return starlark.None, err
+		}
+	}
+	if err := ma.Finish(); err != nil {
+		return starlark.None, err
+	}
+	return ToValue(nb.Build())
+}
+
+// mapKeyString gets the string form of a map key, which is used to track
+// changes to the map. For typed keys this is the key's representation,
+// which is a string for most key types, but may also be an int.
+func mapKeyString(nkey ipldmodel.Node) (string, error) {
+	if tn, ok := nkey.(schema.TypedNode); ok {
+		nkey = tn.Representation()
+	}
+	switch nkey.Kind() {
+	case ipldmodel.Kind_String:
+		return nkey.AsString()
+	case ipldmodel.Kind_Int:
+		i, err := nkey.AsInt()
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatInt(i, 10), nil
+	}
+	return "", fmt.Errorf("map keys of kind %s are not supported", nkey.Kind())
+}
+
+// mapKeyName gets the string key for an untyped map from either a starlark
+// string or a datalark string value
+func mapKeyName(skey starlark.Value) (string, error) {
+	switch it := skey.(type) {
+	case starlark.String:
+		return string(it), nil
+	case Value:
+		if name, err := it.Node().AsString(); err == nil {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("cannot index map using %v of type %s", skey, skey.Type())
+}
+
+func appendAsHost(hostList []starlark.Value, n ipldmodel.Node) ([]starlark.Value, error) {
+	h, err := ToValue(n)
+	if err != nil {
+		return nil, err
+	}
+	return append(hostList, h), nil
+}
+
 func appendTwoItemListAsHost(hostList []starlark.Value, none ipldmodel.Node, ntwo ipldmodel.Node) ([]starlark.Value, error) {
-	h := nodeToHost(none)
-	g := nodeToHost(ntwo)
+	h, err := ToValue(none)
+	if err != nil {
+		return nil, err
+	}
+	g, err := ToValue(ntwo)
+	if err != nil {
+		return nil, err
+	}
 	newHostList, err := NewList(starlark.NewList([]starlark.Value{h, g}))
 	if err != nil {
 		return nil, err
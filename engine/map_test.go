@@ -2,6 +2,10 @@ package datalarkengine
 
 import (
 	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"go.starlark.net/starlark"
 )
 
 func TestMapAndLookup(t *testing.T) {
@@ -698,3 +702,281 @@ map{
 `)
 
 }
+
+func TestMapIterate(t *testing.T) {
+	// iterate over keys, including pending additions and deletions
+	mustParseSchemaRunScriptAssertOutput(t,
+		`
+	`,
+		`mytypes`,
+		`
+m = datalark.Map(_={'a': 'apple', 'b': 'banana', 'c': 'cherry'})
+m['d'] = 'durian'
+m.pop('b')
+def show():
+	for k in m:
+		print(k)
+show()
+print(list(m))
+print([m[k] for k in m])
+print('a' in m)
+print('b' in m)
+print('d' in m)
+`, `
+string{"a"}
+string{"c"}
+string{"d"}
+[string{"a"}, string{"c"}, string{"d"}]
+[string{"apple"}, string{"cherry"}, string{"durian"}]
+True
+False
+True
+`)
+
+	// mutating while iterating is an error
+	_, err := runScript(nil, "mytypes", `
+m = datalark.Map(_={'a': 'apple', 'b': 'banana'})
+def f():
+	for k in m:
+		m['c'] = 'cherry'
+f()
+`)
+	qt.Assert(t, err, qt.ErrorMatches, `cannot insert into map during iteration`)
+
+	_, err = runScript(nil, "mytypes", `
+m = datalark.Map(_={'a': 'apple', 'b': 'banana'})
+def f():
+	for k in m:
+		m.pop(k)
+f()
+`)
+	qt.Assert(t, err, qt.ErrorMatches, `cannot delete from map during iteration`)
+
+	// after iterating, the map can be changed again
+	mustParseSchemaRunScriptAssertOutput(t,
+		`
+	`,
+		`mytypes`,
+		`
+m = datalark.Map(_={'a': 'apple', 'b': 'banana'})
+def f():
+	for k in m:
+		pass
+f()
+m['c'] = 'cherry'
+print(len(m))
+`, `
+3
+`)
+
+	// printing the map while iterating applies its pending changes,
+	// which doesn't change the keys being iterated
+	mustParseSchemaRunScriptAssertOutput(t,
+		`
+	`,
+		`mytypes`,
+		`
+m = datalark.Map(_={'a': 'apple', 'b': 'banana'})
+m['c'] = 'cherry'
+m.pop('a')
+def f():
+	for k in m:
+		print(k, len(str(m)) > 0)
+f()
+`, `
+string{"b"} True
+string{"c"} True
+`)
+}
+
+func TestTypedMapAssign(t *testing.T) {
+	schemaText := `
+		type Foo struct {
+			a String
+			b String
+		} representation stringjoin {
+			join ":"
+		}
+		type Lookup {String:Foo}
+		type Box struct {
+			counts {String:Int}
+		}
+	`
+
+	// values may be given as typed values, or by their representation
+	mustParseSchemaRunScriptAssertOutput(t, schemaText, "mytypes", `
+		m = mytypes.Lookup(k="a:b")
+		m["j"] = mytypes.Foo(a="c", b="d")
+		m["k"] = "e:f"
+		print(type(m))
+		print(m)
+	`, `
+		datalark.Map<Lookup>
+		map<Lookup>{
+			string<String>{"k"}: struct<Foo>{
+				a: string<String>{"e"}
+				b: string<String>{"f"}
+			}
+			string<String>{"j"}: struct<Foo>{
+				a: string<String>{"c"}
+				b: string<String>{"d"}
+			}
+		}
+	`)
+
+	// anonymous map types are shown using schema syntax
+	mustParseSchemaRunScriptAssertOutput(t, schemaText, "mytypes", `
+		box = mytypes.Box(counts={"a": 1})
+		print(type(box.counts))
+	`, `
+		datalark.Map<{String:Int}>
+	`)
+
+	defines := mustParseSchemaDefines(t, schemaText)
+	_, err := runScript(defines, "mytypes", `
+		m = mytypes.Lookup()
+		m["x"] = 4
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `cannot assign 4 to datalark.Map<Lookup>: .*`)
+}
+
+func TestMapNonStringKeys(t *testing.T) {
+	schemaText := `
+		type IntMap {Int:String}
+		type Color enum {
+			| Red ("r")
+			| Green ("g")
+		}
+		type ColorMap {Color:String}
+		type FooBar struct {
+			foo String
+			bar String
+		} representation stringjoin {
+			join ":"
+		}
+		type FooBarMap {FooBar:String}
+	`
+
+	// int keys
+	mustParseSchemaRunScriptAssertOutput(t, schemaText, "mytypes", `
+		m = mytypes.IntMap(_={1: "one", 2: "two"})
+		m[3] = "three"
+		m[1] = "uno"
+		print(m.pop(2))
+		print(m[1])
+		print(1 in m, 2 in m)
+		print([k for k in m])
+		print(m)
+	`, `
+		string<String>{"two"}
+		string<String>{"uno"}
+		True False
+		[int<Int>{1}, int<Int>{3}]
+		map<IntMap>{
+			int<Int>{1}: string<String>{"uno"}
+			int<Int>{3}: string<String>{"three"}
+		}
+	`)
+
+	// enum keys, using either member names or their representation
+	mustParseSchemaRunScriptAssertOutput(t, schemaText, "mytypes", `
+		m = mytypes.ColorMap(_={"Red": "apple", "g": "lime"})
+		print(m["r"])
+		print(m[mytypes.Color("Green")])
+		m["Green"] = "pear"
+		print(m["g"])
+		print([k.name for k in m])
+		print(m)
+	`, `
+		string<String>{"apple"}
+		string<String>{"lime"}
+		string<String>{"pear"}
+		["Red", "Green"]
+		map<ColorMap>{
+			enum<Color>{"Red"}: string<String>{"apple"}
+			enum<Color>{"Green"}: string<String>{"pear"}
+		}
+	`)
+
+	// struct keys, using either struct values or their stringjoin representation
+	mustParseSchemaRunScriptAssertOutput(t, schemaText, "mytypes", `
+		m = mytypes.FooBarMap(_={"a:b": "first", mytypes.FooBar(foo="c", bar="d"): "second"})
+		m["e:f"] = "third"
+		print(m[mytypes.FooBar(foo="a", bar="b")])
+		print(m["c:d"])
+		m.pop("a:b")
+		print(m)
+	`, `
+		string<String>{"first"}
+		string<String>{"second"}
+		map<FooBarMap>{
+			struct<FooBar>{foo: string<String>{"c"}, bar: string<String>{"d"}}: string<String>{"second"}
+			struct<FooBar>{foo: string<String>{"e"}, bar: string<String>{"f"}}: string<String>{"third"}
+		}
+	`)
+
+	defines := mustParseSchemaDefines(t, schemaText)
+	_, err := runScript(defines, "mytypes", `
+		m = mytypes.IntMap()
+		m["a"] = "b"
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `cannot use "a" as a key of datalark.Map<IntMap>: .*`)
+
+	_, err = runScript(defines, "mytypes", `
+		m = mytypes.ColorMap()
+		m["Blue"] = "b"
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `cannot use "Blue" as a key of datalark.Map<ColorMap>: "Blue" is not a valid member of enum Color .*`)
+
+	_, err = runScript(defines, "mytypes", `
+		m = mytypes.IntMap(_={1: "one"})
+		m[5]
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `.*key 5 not in datalark.Map<IntMap>`)
+}
+
+func TestMapFrozen(t *testing.T) {
+	dict := starlark.NewDict(1)
+	qt.Assert(t, dict.SetKey(starlark.String("a"), starlark.String("apple")), qt.IsNil)
+	nb := basicnode.Prototype.Map.NewBuilder()
+	qt.Assert(t, assembleFrom(nb, dict, BigIntError), qt.IsNil)
+	val, err := newMapValue(nb.Build())
+	qt.Assert(t, err, qt.IsNil)
+	val.Freeze()
+
+	err = val.(starlark.HasSetKey).SetKey(starlark.String("b"), starlark.String("banana"))
+	qt.Assert(t, err, qt.ErrorMatches, `cannot insert into frozen map`)
+	_, err = starlark.Call(&starlark.Thread{}, mustAttr(t, val, "clear"), nil, nil)
+	qt.Assert(t, err, qt.ErrorMatches, `cannot clear frozen map`)
+}
+
+func TestMapAssignStarlarkComposites(t *testing.T) {
+	mustParseSchemaRunScriptAssertOutput(t, "", "", `
+		m = datalark.Map(a=1)
+		m["k"] = {"b": 1, "a": (1, 2)}
+		l = datalark.List(_=[])
+		l.append((3, 4))
+		l.append({"x": [5]})
+		print(m["k"])
+		print(l)
+	`, `
+		map{
+			string{"b"}: int{1}
+			string{"a"}: list{
+				0: int{1}
+				1: int{2}
+			}
+		}
+		list{
+			0: list{
+				0: int{3}
+				1: int{4}
+			}
+			1: map{
+				string{"x"}: list{
+					0: int{5}
+				}
+			}
+		}
+	`)
+}
@@ -0,0 +1,258 @@
+package datalarkengine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/schema"
+	"go.starlark.net/starlark"
+)
+
+// withPathMethod returns the "with_path" method of a struct or union, which
+// returns a copy of the value with the data at a path, like "a.b.c", set to
+// a new value. Lists and maps have the same method in their method tables.
+// Scalars, enums and links have no parts to set, so they have no with_path
+func withPathMethod(v Value) *starlark.Builtin {
+	return starlark.NewBuiltin("with_path", guardBuiltin(func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var starPath, val starlark.Value
+		if err := starlark.UnpackArgs(b.Name(), args, kwargs, "path", &starPath, "value", &val); err != nil {
+			return starlark.None, err
+		}
+		return withPathOf(v, starPath, val)
+	}))
+}
+
+// withPathOf splits up the path given to with_path, and makes the updated copy.
+// Each part of the path is a struct field, union member, map key, or list index
+func withPathOf(v Value, starPath, val starlark.Value) (Value, error) {
+	segments, err := pathSegments(starPath)
+	if err != nil {
+		return nil, fmt.Errorf("with_path: %w", err)
+	}
+	res, err := withPath(v, segments, val)
+	if err != nil {
+		if tn, ok := v.Node().(schema.TypedNode); ok {
+			err = inType(err, tn.Type().Name())
+		}
+		return nil, fmt.Errorf("with_path: %w", err)
+	}
+	return res, nil
+}
+
+// pathSegments gets the parts of a path, which is either a string with the
+// parts separated by dots, or a list or tuple of the parts themselves, for
+// map keys that have dots in them. List indexes may be given as ints there
+func pathSegments(starPath starlark.Value) ([]string, error) {
+	var parts []starlark.Value
+	switch x := starPath.(type) {
+	case *starlark.List:
+		for i := 0; i < x.Len(); i++ {
+			parts = append(parts, x.Index(i))
+		}
+	case starlark.Tuple:
+		parts = x
+	default:
+		path, err := textOf(starPath)
+		if err != nil {
+			return nil, fmt.Errorf("path must be a string, or a list of its parts: %w", err)
+		}
+		segments := strings.Split(path, ".")
+		for _, seg := range segments {
+			if seg == "" {
+				return nil, fmt.Errorf("invalid path %q, it has an empty part", path)
+			}
+		}
+		return segments, nil
+	}
+
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("invalid path, it has no parts")
+	}
+	segments := make([]string, len(parts))
+	for i, part := range parts {
+		if n, ok := part.(starlark.Int); ok {
+			segments[i] = n.String()
+			continue
+		}
+		seg, err := textOf(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path, part %d must be a string or int: %w", i, err)
+		}
+		segments[i] = seg
+	}
+	return segments, nil
+}
+
+// withPath returns a copy of the value with the data at the path set to val.
+// Only the values along the path are copied, everything else is shared
+func withPath(v Value, segments []string, val starlark.Value) (Value, error) {
+	seg, rest := segments[0], segments[1:]
+	switch it := v.(type) {
+	case *structValue:
+		return it.withPath(seg, rest, val)
+	case *unionValue:
+		return it.withPath(seg, rest, val)
+	case *mapValue:
+		return it.withPath(seg, rest, val)
+	case *listValue:
+		return it.withPath(seg, rest, val)
+	}
+	return nil, fmt.Errorf("cannot set %q inside of %s", seg, v.Type())
+}
+
+// childWithPath gets the new value for a child at seg, which is val itself if
+// the path ends there, or else a copy of the child with the rest of the path set
+func childWithPath(child datamodel.Node, seg string, rest []string, val starlark.Value) (starlark.Value, error) {
+	if len(rest) == 0 {
+		return val, nil
+	}
+	if child.IsAbsent() {
+		return nil, atPath(fmt.Errorf("cannot set %q inside of an absent field", rest[0]), seg)
+	}
+	cv, err := ToValue(child)
+	if err != nil {
+		return nil, err
+	}
+	res, err := withPath(cv, rest, val)
+	if err != nil {
+		return nil, atPath(err, seg)
+	}
+	return res, nil
+}
+
+func (v *structValue) withPath(seg string, rest []string, val starlark.Value) (Value, error) {
+	typ := v.node.(schema.TypedNode).Type().(*schema.TypeStruct)
+	if typ.Field(seg) == nil {
+		valid := make([]string, 0, len(typ.Fields()))
+		for _, f := range typ.Fields() {
+			valid = append(valid, f.Name())
+		}
+		return nil, unknownFieldError(typ, seg, valid)
+	}
+	child, err := v.node.LookupByString(seg)
+	if err != nil {
+		return nil, err
+	}
+	newChild, err := childWithPath(child, seg, rest, val)
+	if err != nil {
+		return nil, err
+	}
+	return v.withFields([]string{seg}, []starlark.Value{newChild})
+}
+
+// withPath for a union goes into the value of the active member. The last
+// part of the path may also name another member, to switch to that member
+func (v *unionValue) withPath(seg string, rest []string, val starlark.Value) (Value, error) {
+	tn := v.node.(schema.TypedNode)
+	typ := tn.Type().(*schema.TypeUnion)
+	names := make([]string, 0, len(typ.Members()))
+	isMember := false
+	for _, m := range typ.Members() {
+		names = append(names, m.Name())
+		isMember = isMember || m.Name() == seg
+	}
+	if !isMember {
+		return nil, fmt.Errorf("%q is not a member of union %s (members: %s)", seg, typ.Name(), strings.Join(names, ", "))
+	}
+	member, n, err := v.active()
+	if err != nil {
+		return nil, err
+	}
+	newMember := val
+	if member == seg {
+		if newMember, err = childWithPath(n, seg, rest, val); err != nil {
+			return nil, err
+		}
+	} else if len(rest) > 0 {
+		return nil, fmt.Errorf("union %s holds a %s, not a %s", typ.Name(), member, seg)
+	}
+
+	tp := tn.Prototype().(schema.TypedPrototype)
+	argseq := &ArgSeq{vals: []starlark.Value{newMember}, keys: []starlark.Value{starlark.String(seg)}, names: []string{seg}}
+	res, err := constructTypedValue(&Prototype{name: typ.Name(), np: tp, mode: TypedMode}, tp, argseq)
+	if err != nil {
+		return nil, err
+	}
+	return res.(Value), nil
+}
+
+// withPath for a map sets the value of a key, which is added if the path ends
+// there and the map doesn't have it yet
+func (v *mapValue) withPath(seg string, rest []string, val starlark.Value) (Value, error) {
+	// keys of typed maps may be ints, which are written in the path as decimal
+	var key starlark.Value = starlark.String(seg)
+	if _, err := v.keyNode(key); err != nil {
+		if n, convErr := strconv.ParseInt(seg, 10, 64); convErr == nil {
+			key = starlark.MakeInt64(n)
+		}
+	}
+	newChild := val
+	if len(rest) > 0 {
+		child, found, err := v.Get(key)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, fmt.Errorf("key %q is not in %s", seg, v.Type())
+		}
+		if newChild, err = childWithPath(child.(Value).Node(), seg, rest, val); err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := mapMethodCopy(nil, v, nil)
+	if err != nil {
+		return nil, err
+	}
+	build := res.(*mapValue)
+	if err := build.SetKey(key, newChild); err != nil {
+		return nil, err
+	}
+	return build, nil
+}
+
+// withPath for a list sets the element at an index, which may be negative
+// to count from the end, as in starlark
+func (v *listValue) withPath(seg string, rest []string, val starlark.Value) (Value, error) {
+	i, err := strconv.Atoi(seg)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not an index of %s", seg, v.Type())
+	}
+	size := v.Len()
+	if i < 0 {
+		i += size
+	}
+	child, err := v.nodeAt(i)
+	if err != nil {
+		return nil, atPath(err, seg)
+	}
+	newChild, err := childWithPath(child, seg, rest, val)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]datamodel.Node, size)
+	for j := range items {
+		if items[j], err = v.nodeAt(j); err != nil {
+			return nil, err
+		}
+	}
+	if items[i], err = v.elementNode(newChild, BigIntError); err != nil {
+		return nil, err
+	}
+	node, err := buildListFrom(v.Node().Prototype(), items)
+	if err != nil {
+		return nil, err
+	}
+	return newListValue(node)
+}
+
+func listMethodWithPath(_ *starlark.Thread, lv *listValue, args []starlark.Value) (starlark.Value, error) {
+	return withPathOf(lv, args[0], args[1])
+}
+
+func mapMethodWithPath(_ *starlark.Thread, mv *mapValue, args []starlark.Value) (starlark.Value, error) {
+	return withPathOf(mv, args[0], args[1])
+}
@@ -0,0 +1,158 @@
+package datalarkengine
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/printer"
+	"github.com/ipld/go-ipld-prime/schema"
+)
+
+// nodePrinter prints nodes in the same format as the ipld printer, which
+// panics on enums, wherever they are. So maps, lists, structs and unions are
+// walked here, enums are printed here, and only the other scalars are left to
+// the ipld printer. Keys of typed maps are printed through their typed nodes,
+// so enum and struct keys keep their types
+type nodePrinter struct {
+	w io.Writer
+}
+
+type printState uint8
+
+const (
+	// printNormal starts a new line
+	printNormal printState = iota
+	// printKey starts a new line for a map key, which structs print on one line
+	printKey
+	// printValue continues the line of a key
+	printValue
+	// printOneline continues a line, and keeps everything inside on it too
+	printOneline
+)
+
+func (p *nodePrinter) write(s string) {
+	io.WriteString(p.w, s)
+}
+
+func (p *nodePrinter) indent(level int) {
+	for i := 0; i < level; i++ {
+		p.write("\t")
+	}
+}
+
+func (p *nodePrinter) print(level int, state printState, n datamodel.Node) {
+	if state == printNormal || state == printKey {
+		p.indent(level)
+	}
+	tn, ok := n.(schema.TypedNode)
+	if !ok || tn.Type() == nil {
+		switch n.Kind() {
+		case datamodel.Kind_Map, datamodel.Kind_List:
+			p.write(n.Kind().String())
+			p.printContents(level, n)
+		default:
+			p.write(printer.Sprint(n))
+		}
+		return
+	}
+
+	switch typ := tn.Type().(type) {
+	case *schema.TypeEnum:
+		p.write(fmt.Sprintf("enum<%s>", typ.Name()))
+		name, err := enumMemberOfNode(typ, tn)
+		if err != nil {
+			p.write("{?!}")
+			return
+		}
+		p.write(fmt.Sprintf("{%s}", strconv.QuoteToGraphic(name)))
+	case *schema.TypeStruct:
+		p.write(fmt.Sprintf("struct<%s>", typ.Name()))
+		p.printStruct(level, state, n)
+	case *schema.TypeUnion:
+		p.write(fmt.Sprintf("union<%s>{", typ.Name()))
+		if _, v, err := n.MapIterator().Next(); err == nil {
+			p.print(level, printValue, v)
+		}
+		p.write("}")
+	case *schema.TypeMap, *schema.TypeList:
+		p.write(fmt.Sprintf("%s<%s>", typ.TypeKind(), typ.Name()))
+		p.printContents(level, n)
+	default:
+		p.write(printer.Sprint(n))
+	}
+}
+
+// printStruct prints the fields of a struct, which are on one line when the
+// struct is a map key, or inside of one
+func (p *nodePrinter) printStruct(level int, state printState, n datamodel.Node) {
+	oneline := state == printKey || state == printOneline
+	childState := printValue
+	if oneline {
+		childState = printOneline
+	}
+	p.write("{")
+	if !oneline && n.Length() > 0 {
+		p.write("\n")
+	}
+	for itr := n.MapIterator(); !itr.Done(); {
+		k, v, _ := itr.Next()
+		if !oneline {
+			p.indent(level + 1)
+		}
+		name, _ := k.AsString()
+		p.write(name)
+		p.write(": ")
+		p.print(level+1, childState, v)
+		if !oneline {
+			p.write("\n")
+		} else if !itr.Done() {
+			p.write(", ")
+		}
+	}
+	if !oneline {
+		p.indent(level)
+	}
+	p.write("}")
+}
+
+// printContents prints the entries of a map, or the elements of a list, one per line
+func (p *nodePrinter) printContents(level int, n datamodel.Node) {
+	p.write("{")
+	if n.Length() == 0 {
+		p.write("}")
+		return
+	}
+	p.write("\n")
+	if n.Kind() == datamodel.Kind_Map {
+		for itr := n.MapIterator(); !itr.Done(); {
+			k, v, err := itr.Next()
+			if err != nil {
+				p.indent(level + 1)
+				p.write("!! map iteration step yielded error: " + err.Error() + "\n")
+				break
+			}
+			p.print(level+1, printKey, k)
+			p.write(": ")
+			p.print(level+1, printValue, v)
+			p.write("\n")
+		}
+	} else {
+		for itr := n.ListIterator(); !itr.Done(); {
+			idx, v, err := itr.Next()
+			if err != nil {
+				p.indent(level + 1)
+				p.write("!! list iteration step yielded error: " + err.Error() + "\n")
+				break
+			}
+			p.indent(level + 1)
+			p.write(strconv.FormatInt(idx, 10))
+			p.write(": ")
+			p.print(level+1, printValue, v)
+			p.write("\n")
+		}
+	}
+	p.indent(level)
+	p.write("}")
+}
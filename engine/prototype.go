@@ -3,6 +3,7 @@ package datalarkengine
 import (
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/ipld/go-ipld-prime/datamodel"
@@ -61,7 +62,8 @@ func (p *Prototype) Truth() starlark.Bool {
 	return true
 }
 func (p *Prototype) Hash() (uint32, error) {
-	return 0, nil
+	// prototypes are only equal to themselves, so the name is as good a hash as any
+	return starlark.String(p.name).Hash()
 }
 
 // -- starlark.Callable -->
@@ -78,10 +80,20 @@ func (p *Prototype) Attr(name string) (starlark.Value, error) {
 	} else if name == "Repr" {
 		return &Prototype{name: p.name, np: p.np, mode: ReprMode}, nil
 	}
+	if npt, ok := p.np.(schema.TypedPrototype); ok {
+		if typ, ok := npt.Type().(*schema.TypeEnum); ok && name == "members" {
+			return enumMembersBuiltin(typ), nil
+		}
+	}
 	return starlark.None, nil
 }
 
 func (p *Prototype) AttrNames() []string {
+	if npt, ok := p.np.(schema.TypedPrototype); ok {
+		if _, ok := npt.Type().(*schema.TypeEnum); ok {
+			return []string{"Repr", "Typed", "members"}
+		}
+	}
 	return []string{"Repr", "Typed"}
 }
 
@@ -90,15 +102,17 @@ func (p *Prototype) AttrNames() []string {
 // as is the case for keyword args or for restructured args
 type ArgSeq struct {
 	vals []starlark.Value
-	// ckey is used to store compound keys, such as a typed map with a
-	// struct for a key. It is somewhat of a hack, intended to fix the
-	// test `Example_mapWithStructKeys`. Ideally it shouldn't be needed, or
-	// it should be generalized.
-	ckey []starlark.Value
+	// keys are the keys exactly as given, for args that are a mapping
+	// (kwargs, or a dict for restructuring). Unlike names, they keep any
+	// type, which is needed for typed maps that have non-string keys
+	keys []starlark.Value
 	// names is the ordered list of named keys
 	names []string
 	// scalar is whether the arguments is a single scalar value
 	scalar bool
+	// bigInts is the policy for ints in the arguments that are too big,
+	// which comes from the thread that called the constructor
+	bigInts BigIntPolicy
 }
 
 func buildArgSeq(args starlark.Tuple, kwargs []starlark.Tuple) (*ArgSeq, error) {
@@ -130,7 +144,7 @@ func buildArgSeq(args starlark.Tuple, kwargs []starlark.Tuple) (*ArgSeq, error)
 		if dict, ok := kwargs[0][1].(*starlark.Dict); ok {
 			keys := dict.Keys()
 			argseq.vals = make([]starlark.Value, len(keys))
-			argseq.ckey = make([]starlark.Value, len(keys))
+			argseq.keys = make([]starlark.Value, len(keys))
 			argseq.names = make([]string, len(keys))
 			for i := 0; i < len(keys); i++ {
 				argseq.names[i] = asString(keys[i])
@@ -138,7 +152,7 @@ func buildArgSeq(args starlark.Tuple, kwargs []starlark.Tuple) (*ArgSeq, error)
 				if err != nil {
 					return nil, err
 				}
-				argseq.ckey[i] = keys[i]
+				argseq.keys[i] = keys[i]
 				argseq.vals[i] = val
 			}
 			return argseq, nil
@@ -147,11 +161,11 @@ func buildArgSeq(args starlark.Tuple, kwargs []starlark.Tuple) (*ArgSeq, error)
 	case len(kwargs) > 0:
 		// keyword args
 		argseq.vals = make([]starlark.Value, len(kwargs))
-		argseq.ckey = make([]starlark.Value, len(kwargs))
+		argseq.keys = make([]starlark.Value, len(kwargs))
 		argseq.names = make([]string, len(kwargs))
 		for i := 0; i < len(kwargs); i++ {
 			argseq.names[i] = asString(kwargs[i][0])
-			argseq.ckey[i] = kwargs[i][0]
+			argseq.keys[i] = kwargs[i][0]
 			argseq.vals[i] = kwargs[i][1]
 		}
 		return argseq, nil
@@ -215,25 +229,283 @@ func getStructFieldInfo(structObj *schema.TypeStruct) ([]starlark.Value, *requir
 	return result, &requireInfo{allowed: allowed, needed: needed}
 }
 
-func findMemberMatch(unionObj *schema.TypeUnion, val starlark.Value) starlark.String {
-	typeName := val.Type()
+// validateStructFieldNames checks the names given to a struct constructor: each
+// must name a field (by its representation key too, if the mode allows that),
+// no field may be given twice, and every required field must be given. It
+// returns the index of the field that each name is for
+func validateStructFieldNames(st *schema.TypeStruct, mode Mode, names []string) ([]int, error) {
+	fields := st.Fields()
+	mapRepr, hasMapRepr := st.RepresentationStrategy().(schema.StructRepresentation_Map)
+	useFieldNames := mode != ReprMode || !hasMapRepr
+	useReprKeys := mode != TypedMode && hasMapRepr
+
+	// which field each valid name refers to; field names come first, so they
+	// win if a representation key is the same as the name of another field
+	lookup := make(map[string]int, len(fields))
+	var valid []string
+	addName := func(name string, i int) {
+		if _, ok := lookup[name]; !ok {
+			lookup[name] = i
+			valid = append(valid, name)
+		}
+	}
+	for i, f := range fields {
+		if useFieldNames {
+			addName(f.Name(), i)
+		}
+	}
+	for i, f := range fields {
+		if useReprKeys {
+			addName(mapRepr.GetFieldKey(f), i)
+		}
+	}
+
+	given := make(map[int]string, len(names))
+	indexes := make([]int, len(names))
+	for n, name := range names {
+		i, ok := lookup[name]
+		if !ok {
+			return nil, unknownFieldError(st, name, valid)
+		}
+		if prev, ok := given[i]; ok {
+			return nil, fmt.Errorf("field %q of struct %s is given more than once (as %q and %q)", fields[i].Name(), st.Name(), prev, name)
+		}
+		given[i] = name
+		indexes[n] = i
+	}
+
+	var missing []string
+	for i, f := range fields {
+		if _, ok := given[i]; ok || f.IsOptional() {
+			continue
+		}
+		// fields with implicit values can be left out, and get filled in
+		if hasMapRepr && mapRepr.FieldImplicit(f) != nil {
+			continue
+		}
+		missing = append(missing, fmt.Sprintf("%q", f.Name()))
+	}
+	switch len(missing) {
+	case 0:
+		return indexes, nil
+	case 1:
+		return nil, fmt.Errorf("missing required field %s of struct %s", missing[0], st.Name())
+	default:
+		return nil, fmt.Errorf("missing required fields %s of struct %s", strings.Join(missing, ", "), st.Name())
+	}
+}
+
+// structArgsByName returns the names and values to construct a struct with,
+// given the index of the field that each named arg is for. The type-level
+// names are the field names, and the representation-level names are the keys
+// of a map representation, whichever of those the args used. Fields that
+// weren't given, but have implicit values, are added with those values
+func structArgsByName(st *schema.TypeStruct, argseq *ArgSeq, indexes []int) (typedNames, reprNames []starlark.Value, vals []starlark.Value) {
+	fields := st.Fields()
+	mapRepr, hasMapRepr := st.RepresentationStrategy().(schema.StructRepresentation_Map)
+	given := make(map[int]bool, len(indexes))
+	add := func(i int, reprName string, val starlark.Value) {
+		given[i] = true
+		typedNames = append(typedNames, starlark.String(fields[i].Name()))
+		reprNames = append(reprNames, starlark.String(reprName))
+		vals = append(vals, val)
+	}
+	for n, i := range indexes {
+		reprName := argseq.names[n]
+		if hasMapRepr {
+			reprName = mapRepr.GetFieldKey(fields[i])
+		}
+		add(i, reprName, argseq.vals[n])
+	}
+	if hasMapRepr {
+		for i, f := range fields {
+			if given[i] {
+				continue
+			}
+			if val := implicitValue(mapRepr.FieldImplicit(f)); val != nil {
+				add(i, mapRepr.GetFieldKey(f), val)
+			}
+		}
+	}
+	return typedNames, reprNames, vals
+}
+
+// implicitValue returns the starlark value for the implicit value of a field,
+// or nil if there isn't one
+func implicitValue(iv schema.ImplicitValue) starlark.Value {
+	switch x := iv.(type) {
+	case schema.ImplicitValue_Bool:
+		return starlark.Bool(x)
+	case schema.ImplicitValue_Int:
+		return starlark.MakeInt(int(x))
+	case schema.ImplicitValue_String:
+		return starlark.String(x)
+	case schema.ImplicitValue_EmptyList:
+		return starlark.NewList(nil)
+	case schema.ImplicitValue_EmptyMap:
+		return starlark.NewDict(0)
+	}
+	return nil
+}
+
+// unknownFieldError is the error for a name that isn't one of the valid names
+// for the fields of a struct, suggesting the closest one if there is one
+func unknownFieldError(st *schema.TypeStruct, name string, valid []string) error {
+	if suggestion := closestName(name, valid); suggestion != "" {
+		return fmt.Errorf("%q is not a field of struct %s (did you mean %q?)", name, st.Name(), suggestion)
+	}
+	return fmt.Errorf("%q is not a field of struct %s (fields: %s)", name, st.Name(), strings.Join(valid, ", "))
+}
+
+// closestName returns whichever of the candidates the name is most likely a
+// misspelling of, or "" if none are close enough
+func closestName(name string, candidates []string) string {
+	best, bestDist := "", (len(name)+2)/3
+	if bestDist < 1 {
+		bestDist = 1
+	}
+	for _, c := range candidates {
+		if strings.EqualFold(name, c) {
+			return c
+		}
+		if d := editDistance(name, c); d <= bestDist && (best == "" || d < editDistance(name, best)) {
+			best = c
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = prev[j-1] + cost
+			if prev[j]+1 < curr[j] {
+				curr[j] = prev[j] + 1
+			}
+			if curr[j-1]+1 < curr[j] {
+				curr[j] = curr[j-1] + 1
+			}
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// findMemberMatch decides which member of a union a single value is for, and
+// returns the member's name along with the value to construct it from. Typed
+// values of a member type are used as they are. Otherwise the union's
+// representation decides: kinded unions go by the data kind of the value, and
+// stringprefix unions by the prefix of a string, which then gets removed
+func findMemberMatch(unionObj *schema.TypeUnion, val starlark.Value) (starlark.String, starlark.Value, error) {
+	members := unionObj.Members()
 	if v, ok := val.(Value); ok {
 		if tn, ok := v.Node().(schema.TypedNode); ok {
-			// TODO: match Repr as well
-			typeName = tn.Type().Name()
+			for _, m := range members {
+				if m.Name() == tn.Type().Name() {
+					return starlark.String(m.Name()), val, nil
+				}
+			}
 		}
 	}
-	typeName = strings.ToLower(typeName)
 
-	members := unionObj.Members()
+	switch stg := unionObj.RepresentationStrategy().(type) {
+	case schema.UnionRepresentation_Kinded:
+		kind, ok := dataKindOf(val)
+		if !ok {
+			break
+		}
+		if name := stg.GetMember(kind); name != "" {
+			return starlark.String(name), val, nil
+		}
+		kinds := make([]string, len(members))
+		for i, m := range members {
+			kinds[i] = fmt.Sprintf("%s (%s)", m.Name(), m.RepresentationBehavior())
+		}
+		return "", nil, fmt.Errorf("union %s has no member of kind %s (members: %s)", unionObj.Name(), kind, strings.Join(kinds, ", "))
+
+	case schema.UnionRepresentation_Stringprefix:
+		text, err := textOf(val)
+		if err != nil {
+			break
+		}
+		var matches, prefixes []string
+		var match schema.Type
+		for _, m := range members {
+			prefix := stg.GetDiscriminant(m) + stg.GetDelim()
+			prefixes = append(prefixes, fmt.Sprintf("%s (%q)", m.Name(), prefix))
+			if strings.HasPrefix(text, prefix) {
+				matches = append(matches, prefixes[len(prefixes)-1])
+				match = m
+			}
+		}
+		switch len(matches) {
+		case 0:
+			return "", nil, fmt.Errorf("%q does not start with the prefix of any member of union %s (members: %s)", text, unionObj.Name(), strings.Join(prefixes, ", "))
+		case 1:
+			// the rest of the string becomes the member, so it has to be a
+			// string too; bindnode can't assemble other kinds from it
+			if kind := match.RepresentationBehavior(); kind != datamodel.Kind_String {
+				return "", nil, fmt.Errorf("unsupported member kind: member %s of stringprefix union %s is represented as a %s, not a string", match.Name(), unionObj.Name(), kind)
+			}
+			rest := text[len(stg.GetDiscriminant(match)+stg.GetDelim()):]
+			return starlark.String(match.Name()), starlark.String(rest), nil
+		default:
+			return "", nil, fmt.Errorf("%q is ambiguous for union %s, it could be any of: %s", text, unionObj.Name(), strings.Join(matches, ", "))
+		}
+	}
+
+	// otherwise, guess from the name of the value's type
+	typeName := strings.ToLower(val.Type())
+	if v, ok := val.(Value); ok {
+		if tn, ok := v.Node().(schema.TypedNode); ok {
+			typeName = strings.ToLower(tn.Type().Name())
+		}
+	}
 	for _, m := range members {
 		if strings.ToLower(m.Name()) == typeName {
-			return starlark.String(m.Name())
+			return starlark.String(m.Name()), val, nil
 		}
 	}
+	return "", nil, fmt.Errorf("cannot tell which member of union %s to use for %v of type %s", unionObj.Name(), val, val.Type())
+}
 
-	// not found
-	return starlark.String("")
+// dataKindOf returns the data model kind that a value would be assembled as
+func dataKindOf(val starlark.Value) (datamodel.Kind, bool) {
+	switch x := val.(type) {
+	case Value:
+		if tn, ok := x.Node().(schema.TypedNode); ok {
+			return tn.Representation().Kind(), true
+		}
+		return x.Node().Kind(), true
+	case starlark.NoneType:
+		return datamodel.Kind_Null, true
+	case starlark.Bool:
+		return datamodel.Kind_Bool, true
+	case starlark.Int:
+		return datamodel.Kind_Int, true
+	case starlark.Float:
+		return datamodel.Kind_Float, true
+	case starlark.String:
+		return datamodel.Kind_String, true
+	case starlark.Bytes:
+		return datamodel.Kind_Bytes, true
+	case starlark.IterableMapping:
+		return datamodel.Kind_Map, true
+	case starlark.Iterable:
+		return datamodel.Kind_List, true
+	}
+	return datamodel.Kind_Invalid, false
 }
 
 func rangeUpTo(n int) []int {
@@ -244,98 +516,181 @@ func rangeUpTo(n int) []int {
 	return res
 }
 
-func (p *Prototype) CallInternal(thread *starlark.Thread, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+func (p *Prototype) CallInternal(thread *starlark.Thread, args starlark.Tuple, kwargs []starlark.Tuple) (val starlark.Value, err error) {
+	// Construction reaches deep into ipld-prime, which panics on some input
+	// it doesn't expect. Report those to the script like any other error,
+	// rather than taking down the host process
+	defer func() {
+		if r := recover(); r != nil {
+			val, err = starlark.None, fmt.Errorf("internal error constructing %s: %v", p.TypeName(), r)
+		}
+	}()
 	// Prototype is being called with some starlark values. Determine what
 	// the incoming arguments are, and use them to construct an ArgSeq.
 	argseq, err := buildArgSeq(args, kwargs)
 	if err != nil {
 		return starlark.None, err
 	}
+	argseq.bigInts = bigIntPolicyFor(thread)
 	// construct the prototype's desired type using the ArgSeq
 	return constructNewValue(p, argseq)
 }
 
 // construct a new value with type matching the prototype, using the args for its state
 func constructNewValue(p *Prototype, argseq *ArgSeq) (starlark.Value, error) {
+	var val starlark.Value
+	var err error
 	if tp, ok := p.np.(schema.TypedPrototype); ok {
-		// construct a Typed value, such as a type-specific map or union or struct
-		nb := p.np.NewBuilder()
-
-		// state for how to construct each possible type
-		var fieldNames []starlark.Value
-		var ri *requireInfo
-
-		switch it := tp.Type().(type) {
-		case *schema.TypeMap:
-			// typed map might be using complex keys
-			fieldNames = argseq.ckey
-
-		case *schema.TypeUnion:
-			switch len(argseq.names) {
-			case 0:
-				// TODO: What if len(argseq.vals) == 0
-				fieldNames = make([]starlark.Value, 1)
-				fieldNames[0] = findMemberMatch(it, argseq.vals[0])
-			case 1:
-				fieldNames = []starlark.Value{starlark.String(argseq.names[0])}
-			default:
-				// union should only have 1 key in its map
-				return starlark.None, fmt.Errorf("union must be given a map with only 1 key")
-			}
+		val, err = constructTypedValue(p, tp, argseq)
+	} else {
+		val, err = constructBasicValue(p, argseq)
+	}
+	if err != nil {
+		return starlark.None, inType(err, p.TypeName())
+	}
+	return val, nil
+}
 
-		case *schema.TypeStruct:
-			// struct has field names in its type
-			fieldNames, ri = getStructFieldInfo(it)
-			// if names were given for the arguments, use them for construction
-			if argseq.names != nil {
-				if err := ri.ensureValidNumFields(fieldNames, argseq); err != nil {
-					return starlark.None, err
-				}
-				// TODO(dustmop): validate them against the struct
-				fieldNames = make([]starlark.Value, len(argseq.names))
-				for i, name := range argseq.names {
-					fieldNames[i] = starlark.String(name)
-				}
-			}
+// construct a Typed value, such as a type-specific map or union or struct
+func constructTypedValue(p *Prototype, tp schema.TypedPrototype, argseq *ArgSeq) (starlark.Value, error) {
+	// the representation constructor takes back any representation view
+	// exactly as it is, such as one from datalark.repr. Enums are left to
+	// their own rules, which check the representation strings properly
+	if p.mode == ReprMode && argseq.scalar && isUntyped(argseq.vals[0]) && tp.Type().TypeKind() != schema.TypeKind_Enum {
+		val, reprErr := constructFromRepresentation(tp, argseq.vals[0], argseq.bigInts)
+		if reprErr == nil {
+			return val, nil
+		}
+		// the other ways of construction may still work, but if they don't,
+		// the error from the whole representation is the one to report
+		if val, err := constructTypedValueFromArgs(p, tp, argseq); err == nil {
+			return val, nil
+		}
+		return starlark.None, reprErr
+	}
+	return constructTypedValueFromArgs(p, tp, argseq)
+}
 
+// construct a Typed value from the args as the parts of the value, such as
+// the fields of a struct or the elements of a list
+func constructTypedValueFromArgs(p *Prototype, tp schema.TypedPrototype, argseq *ArgSeq) (starlark.Value, error) {
+	nb := p.np.NewBuilder()
+
+	// state for how to construct each possible type
+	var fieldNames []starlark.Value
+	var ri *requireInfo
+	// the field names and args for the type-level and representation-level
+	// construction, if they differ from the ones given
+	var typedFieldNames []starlark.Value
+	typedArgs, reprArgs := argseq, argseq
+
+	switch it := tp.Type().(type) {
+	case *schema.TypeEnum:
+		// enums are scalar, and have their own construction rules
+		return constructEnumValue(p, tp, it, argseq)
+
+	case *schema.TypeLink:
+		return constructLinkValue(p, argseq)
+
+	case *schema.TypeList:
+		// elements are positional, rather than named like the other types
+		return constructTypedList(p, tp, argseq)
+
+	case *schema.TypeMap:
+		// keys and values are converted to the map's types one by one
+		return constructTypedMap(p, tp, argseq)
+
+	case *schema.TypeUnion:
+		switch len(argseq.names) {
+		case 0:
+			if len(argseq.vals) != 1 {
+				return starlark.None, fmt.Errorf("union %s must be given one value, or one member by name", it.Name())
+			}
+			member, content, err := findMemberMatch(it, argseq.vals[0])
+			if err != nil {
+				return starlark.None, err
+			}
+			fieldNames = []starlark.Value{member}
+			if content != argseq.vals[0] {
+				// the prefix of a string was used to choose the member
+				typedArgs = &ArgSeq{vals: []starlark.Value{content}, scalar: true, bigInts: argseq.bigInts}
+			}
+		case 1:
+			fieldNames = []starlark.Value{starlark.String(argseq.names[0])}
 		default:
-			return starlark.None, fmt.Errorf("unknown type: %T", it)
+			// union should only have 1 key in its map
+			return starlark.None, fmt.Errorf("union must be given a map with only 1 key")
 		}
 
-		// maybe can be constructed via data-kind representation agreement
-		if p.mode == AnyMode || p.mode == ReprMode {
-			if val, err := constructFromStringRepresentation(tp, argseq); err == nil {
-				return val, nil
+	case *schema.TypeStruct:
+		// a single value with the same kind as the struct's representation,
+		// such as a list for a tuple struct, is its representation
+		if argseq.scalar && p.mode != TypedMode && isUntypedOfKind(argseq.vals[0], it.RepresentationBehavior()) {
+			val, err := constructFromRepresentation(tp, argseq.vals[0], argseq.bigInts)
+			if err == nil || p.mode == ReprMode || countFieldsOfKind(it, it.RepresentationBehavior()) != 1 {
+				return val, err
 			}
-			// ignore error because it was only the first attempt
+			// ignore error because the value is for the one field that takes its kind
 		}
-
-		if ri == nil {
+		// struct has field names in its type
+		fieldNames, ri = getStructFieldInfo(it)
+		// if names were given for the arguments, use them for construction
+		if argseq.names != nil {
+			indexes, err := validateStructFieldNames(it, p.mode, argseq.names)
+			if err != nil {
+				return starlark.None, err
+			}
+			var vals []starlark.Value
+			typedFieldNames, fieldNames, vals = structArgsByName(it, argseq, indexes)
+			typedArgs = &ArgSeq{vals: vals, names: argseq.names, bigInts: argseq.bigInts}
+			reprArgs = typedArgs
+			// the names are all known to be valid, so the count is too
 			ri = &requireInfo{allowed: len(fieldNames), needed: len(fieldNames)}
 		}
 
-		// maybe construct using type agreement
-		if p.mode == AnyMode || p.mode == TypedMode {
-			val, err := constructUsingFieldsValues(nb, fieldNames, ri, argseq)
-			if err == nil {
-				return val, nil
-			} else if p.mode == TypedMode {
-				return starlark.None, err
-			}
-			// ignore error because there is one approach left to try
-			err = nil
+	default:
+		return starlark.None, fmt.Errorf("unknown type: %T", it)
+	}
+
+	// maybe can be constructed via data-kind representation agreement
+	if p.mode == AnyMode || p.mode == ReprMode {
+		if val, err := constructFromStringRepresentation(tp, argseq); err == nil {
+			return val, nil
 		}
+		// ignore error because it was only the first attempt
+	}
 
-		// TODO(dustmop): Is reqInfo supported by representation? Add a test.
-		return constructAsRepresentation(tp, fieldNames, ri, argseq)
+	if ri == nil {
+		ri = &requireInfo{allowed: len(fieldNames), needed: len(fieldNames)}
+	}
+	if typedFieldNames == nil {
+		typedFieldNames = fieldNames
 	}
 
-	return constructBasicValue(p, argseq)
+	// maybe construct using type agreement
+	if p.mode == AnyMode || p.mode == TypedMode {
+		val, err := constructUsingFieldsValues(nb, typedFieldNames, ri, typedArgs)
+		if err == nil {
+			return val, nil
+		} else if p.mode == TypedMode {
+			return starlark.None, err
+		}
+		// ignore error because there is one approach left to try
+		err = nil
+	}
+
+	// TODO(dustmop): Is reqInfo supported by representation? Add a test.
+	return constructAsRepresentation(tp, fieldNames, ri, reprArgs)
 }
 
 func constructBasicValue(p *Prototype, argseq *ArgSeq) (starlark.Value, error) {
 	nb := p.np.NewBuilder()
 
+	// bytes may also be decoded from text, such as `Bytes(hex="1256")`
+	if _, ok := p.np.(basicnode.Prototype__Bytes); ok && len(argseq.names) > 0 {
+		return constructBytesFromText(p, argseq)
+	}
+
 	switch p.np.(type) {
 	case basicnode.Prototype__Bool, basicnode.Prototype__Int, basicnode.Prototype__Float, basicnode.Prototype__String, basicnode.Prototype__Bytes:
 		// scalar value being constucted
@@ -343,11 +698,17 @@ func constructBasicValue(p *Prototype, argseq *ArgSeq) (starlark.Value, error) {
 			return starlark.None, fmt.Errorf("wrong arguments for scalar constructor")
 		}
 		val := argseq.vals[0]
-		if err := assembleFrom(nb, val); err != nil {
+		if err := assembleFrom(nb, val, argseq.bigInts); err != nil {
+			if isBigIntError(err) {
+				return starlark.None, err
+			}
 			gotType := reflect.TypeOf(val).Name()
 			return starlark.None, fmt.Errorf("cannot create %s from %v of type %s", p.TypeName(), val, gotType)
 		}
 
+	case basicnode.Prototype__Link:
+		return constructLinkValue(p, argseq)
+
 	case basicnode.Prototype__List:
 		size := len(argseq.vals)
 		// list value being constructed
@@ -358,10 +719,9 @@ func constructBasicValue(p *Prototype, argseq *ArgSeq) (starlark.Value, error) {
 		if err != nil {
 			return starlark.None, err
 		}
-		for _, val := range argseq.vals {
-			if err := assembleFrom(la.AssembleValue(), val); err != nil {
-				gotType := reflect.TypeOf(val).Name()
-				return starlark.None, fmt.Errorf("cannot create %s from %v of type %s", p.TypeName(), val, gotType)
+		for i, val := range argseq.vals {
+			if err := assembleFrom(la.AssembleValue(), val, argseq.bigInts); err != nil {
+				return starlark.None, atPath(err, strconv.Itoa(i))
 			}
 		}
 		if err := la.Finish(); err != nil {
@@ -378,11 +738,11 @@ func constructBasicValue(p *Prototype, argseq *ArgSeq) (starlark.Value, error) {
 			return starlark.None, err
 		}
 		for i, n := range argseq.names {
-			if err := assembleFrom(ma.AssembleKey(), starlark.String(n)); err != nil {
+			if err := assembleFrom(ma.AssembleKey(), starlark.String(n), argseq.bigInts); err != nil {
 				return starlark.None, err
 			}
-			if err := assembleFrom(ma.AssembleValue(), argseq.vals[i]); err != nil {
-				return starlark.None, err
+			if err := assembleFrom(ma.AssembleValue(), argseq.vals[i], argseq.bigInts); err != nil {
+				return starlark.None, atPath(err, n)
 			}
 		}
 		if err := ma.Finish(); err != nil {
@@ -396,6 +756,30 @@ func constructBasicValue(p *Prototype, argseq *ArgSeq) (starlark.Value, error) {
 	return ToValue(nb.Build())
 }
 
+// constructBytesFromText decodes a single keyword argument, whose name is
+// the encoding of the text, into a bytes value
+func constructBytesFromText(p *Prototype, argseq *ArgSeq) (starlark.Value, error) {
+	if len(argseq.names) != 1 {
+		return starlark.None, fmt.Errorf("%s expects a single encoded value, got %d", p.TypeName(), len(argseq.names))
+	}
+	encoding := argseq.names[0]
+	text, ok := argseq.vals[0].(starlark.String)
+	if !ok {
+		if v, isVal := argseq.vals[0].(Value); isVal && v.Node().Kind() == datamodel.Kind_String {
+			str, _ := v.Node().AsString()
+			text, ok = starlark.String(str), true
+		}
+	}
+	if !ok {
+		return starlark.None, fmt.Errorf("cannot decode %s from %v of type %s, expected a string", p.TypeName(), argseq.vals[0], argseq.vals[0].Type())
+	}
+	data, err := decodeBytes(encoding, string(text))
+	if err != nil {
+		return starlark.None, fmt.Errorf("cannot decode %s from %s: %w", p.TypeName(), encoding, err)
+	}
+	return NewBytes(data), nil
+}
+
 func constructFromStringRepresentation(tp schema.TypedPrototype, argseq *ArgSeq) (starlark.Value, error) {
 	// a single string representation form, such as `Alpha("beta:1")` to assign
 	// the value "1" to the field "beta" of "Alpha". this is handled by the assembler
@@ -403,16 +787,129 @@ func constructFromStringRepresentation(tp schema.TypedPrototype, argseq *ArgSeq)
 		return starlark.None, fmt.Errorf("arguments are not a single string")
 	}
 	nb := tp.Representation().NewBuilder()
-	if err := assembleFrom(nb, argseq.vals[0]); err != nil {
+	if err := assembleFrom(nb, argseq.vals[0], argseq.bigInts); err != nil {
 		return starlark.None, err
 	}
 	return ToValue(nb.Build())
 }
 
 func constructAsRepresentation(tp schema.TypedPrototype, fieldNames []starlark.Value, ri *requireInfo, argseq *ArgSeq) (starlark.Value, error) {
+	if tp.Type().RepresentationBehavior() == datamodel.Kind_List && argseq.names == nil {
+		// a tuple struct, whose representation has the values in field order
+		if err := ri.ensureValidNumFields(fieldNames, argseq); err != nil {
+			return starlark.None, err
+		}
+		return constructFromRepresentation(tp, starlark.NewList(argseq.vals), argseq.bigInts)
+	}
 	return constructUsingFieldsValues(tp.Representation().NewBuilder(), fieldNames, ri, argseq)
 }
 
+// constructFromRepresentation constructs a value from the whole of its
+// representation, such as the list for a tuple struct, or the map for a map
+// struct, to which the values of any missing implicit fields are added
+func constructFromRepresentation(tp schema.TypedPrototype, val starlark.Value, bigInts BigIntPolicy) (starlark.Value, error) {
+	st, isStruct := tp.Type().(*schema.TypeStruct)
+	if !isStruct {
+		nb := tp.Representation().NewBuilder()
+		if err := assembleFrom(nb, val, bigInts); err != nil {
+			return starlark.None, err
+		}
+		return ToValue(nb.Build())
+	}
+	if mapRepr, ok := st.RepresentationStrategy().(schema.StructRepresentation_Map); ok {
+		items, isMap, err := mappingItems(val)
+		if err != nil {
+			return starlark.None, err
+		}
+		if isMap {
+			withImplicits := starlark.NewDict(len(st.Fields()))
+			for _, item := range items {
+				if err := withImplicits.SetKey(item[0], item[1]); err != nil {
+					return starlark.None, err
+				}
+			}
+			for _, f := range st.Fields() {
+				key := starlark.String(mapRepr.GetFieldKey(f))
+				if _, found, _ := withImplicits.Get(key); found {
+					continue
+				}
+				if implicit := implicitValue(mapRepr.FieldImplicit(f)); implicit != nil {
+					if err := withImplicits.SetKey(key, implicit); err != nil {
+						return starlark.None, err
+					}
+				}
+			}
+			val = withImplicits
+		}
+	}
+	nb := tp.Representation().NewBuilder()
+	if err := assembleFrom(nb, val, bigInts); err != nil {
+		return starlark.None, err
+	}
+	return ToValue(nb.Build())
+}
+
+// mappingItems gets the entries of a starlark mapping, or of a datalark map,
+// with the keys as starlark strings, so that they can be looked up by name.
+// Returns false if the value isn't a mapping
+func mappingItems(val starlark.Value) ([]starlark.Tuple, bool, error) {
+	switch it := val.(type) {
+	case *mapValue:
+		names := it.keyNames()
+		items := make([]starlark.Tuple, 0, len(names))
+		for _, name := range names {
+			v, _, err := it.Get(it.keys[name])
+			if err != nil {
+				return nil, false, err
+			}
+			items = append(items, starlark.Tuple{starlark.String(name), v})
+		}
+		return items, true, nil
+	case starlark.IterableMapping:
+		items := it.Items()
+		for _, item := range items {
+			// datalark strings don't match starlark strings as dict keys
+			if v, ok := asBasic(item[0]); ok {
+				key, err := v.toStarlark()
+				if err != nil {
+					return nil, false, err
+				}
+				item[0] = key
+			}
+		}
+		return items, true, nil
+	}
+	return nil, false, nil
+}
+
+// countFieldsOfKind counts the fields of a struct that can take a value of the
+// given kind, which kinded unions and Any fields can for every kind
+func countFieldsOfKind(st *schema.TypeStruct, kind datamodel.Kind) int {
+	count := 0
+	for _, f := range st.Fields() {
+		behavior := f.Type().RepresentationBehavior()
+		if behavior == kind || behavior == datamodel.Kind_Invalid {
+			count++
+		}
+	}
+	return count
+}
+
+// isUntyped returns whether a value is plain data, rather than a value of some type
+func isUntyped(val starlark.Value) bool {
+	if v, ok := val.(Value); ok {
+		_, typed := v.Node().(schema.TypedNode)
+		return !typed
+	}
+	return true
+}
+
+// isUntypedOfKind returns whether a value is plain data of the given kind
+func isUntypedOfKind(val starlark.Value, kind datamodel.Kind) bool {
+	k, ok := dataKindOf(val)
+	return ok && k == kind && isUntyped(val)
+}
+
 // assemble the node as a map of fields and values
 func constructUsingFieldsValues(nb datamodel.NodeBuilder, fieldNames []starlark.Value, ri *requireInfo, argseq *ArgSeq) (starlark.Value, error) {
 	if err := ri.ensureValidNumFields(fieldNames, argseq); err != nil {
@@ -426,11 +923,11 @@ func constructUsingFieldsValues(nb datamodel.NodeBuilder, fieldNames []starlark.
 		if i >= len(argseq.vals) {
 			break
 		}
-		if err := assembleFrom(ma.AssembleKey(), fieldNames[i]); err != nil {
+		if err := assembleFrom(ma.AssembleKey(), fieldNames[i], argseq.bigInts); err != nil {
 			return starlark.None, err
 		}
-		if err := assembleParameter(ma, argseq.vals[i], false); err != nil {
-			return starlark.None, err
+		if err := assembleParameter(ma, argseq.vals[i], false, argseq.bigInts); err != nil {
+			return starlark.None, atPath(err, asString(fieldNames[i]))
 		}
 	}
 	if err := ma.Finish(); err != nil {
@@ -440,9 +937,9 @@ func constructUsingFieldsValues(nb datamodel.NodeBuilder, fieldNames []starlark.
 	return ToValue(nb.Build())
 }
 
-func assembleParameter(ma datamodel.MapAssembler, val starlark.Value, allowRepr bool) error {
+func assembleParameter(ma datamodel.MapAssembler, val starlark.Value, allowRepr bool, bigInts BigIntPolicy) error {
 	na := ma.AssembleValue()
-	err := assembleFrom(na, val)
+	err := assembleFrom(na, val, bigInts)
 	// if `err` is non-nil, it may get reused below
 	if err == nil {
 		return nil
@@ -480,7 +977,7 @@ func assembleParameter(ma datamodel.MapAssembler, val starlark.Value, allowRepr
 
 	// then take the assembler's representation and try using that
 	builder := tp.Representation().NewBuilder()
-	if err := assembleFrom(builder, val); err != nil {
+	if err := assembleFrom(builder, val, bigInts); err != nil {
 		return err
 	}
 	return na.AssignNode(builder.Build())
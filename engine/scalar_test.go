@@ -2,6 +2,9 @@ package datalarkengine
 
 import (
 	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"go.starlark.net/starlark"
 )
 
 func assertEqual(t *testing.T, a interface{}, b interface{}) {
@@ -124,3 +127,269 @@ func TestLenBuiltinForStringAndBytes(t *testing.T) {
 `,
 	)
 }
+
+func TestHashMatchesStarlark(t *testing.T) {
+	pairs := []struct {
+		val  Value
+		star starlark.Value
+	}{
+		{NewNull(), starlark.None},
+		{NewBool(true), starlark.True},
+		{NewInt(34), starlark.MakeInt(34)},
+		{NewFloat(7.2), starlark.Float(7.2)},
+		{NewString("hi"), starlark.String("hi")},
+		{NewBytes([]byte{0x12, 0x56}), starlark.Bytes("\x12\x56")},
+	}
+	for _, pair := range pairs {
+		got, err := pair.val.Hash()
+		qt.Assert(t, err, qt.IsNil)
+		expect, err := pair.star.Hash()
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, got, qt.Equals, expect, qt.Commentf("hash of %s", pair.val))
+	}
+}
+
+func TestHashUsableAsDictKey(t *testing.T) {
+	mustParseSchemaRunScriptAssertOutput(t, `
+		type Ref &Any
+	`, "mytypes", `
+		s = datalark.String("x")
+		n = datalark.Int(3)
+		lnk = mytypes.Ref("bafyreibm6jg3ux5qumhcn2b3flc3tyu6dmlb4xa7u5bf44yegnrjhc4yeq")
+		d = {s: "string", n: "int", lnk: "link", mytypes.Ref: "prototype"}
+		print(d[s], d[n], d[lnk], d[mytypes.Ref])
+		print(len(dict([(s, 1), (n, 2), (s, 3)])))
+	`, `
+		string int link prototype
+		2
+	`)
+
+	// maps and lists are never hashable, like starlark dicts and lists
+	_, err := runScript(nil, "mytypes", `
+		d = {datalark.List(_=[1]): 1}
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `unhashable type: datalark.List`)
+
+	_, err = runScript(nil, "mytypes", `
+		d = {datalark.Map(a=1): 1}
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `unhashable type: datalark.Map`)
+}
+
+func TestCompareBasicValues(t *testing.T) {
+	mustParseSchemaRunScriptAssertOutput(t, "", "", `
+		print(datalark.Int(3) == datalark.Int(3))
+		print(datalark.Int(3) != datalark.Int(4))
+		print(datalark.Int(3) == datalark.Float(3.0))
+		print(datalark.Int(2) < datalark.Float(2.5))
+		print(datalark.String("a") < datalark.String("b"))
+		print(datalark.Bytes(b"b") >= datalark.Bytes(b"a"))
+		print(datalark.String("a") == datalark.Int(1))
+		print(sorted([datalark.String("b"), datalark.String("c"), datalark.String("a")]))
+		print({datalark.String("x"): 1}[datalark.String("x")])
+		# starlark never compares values of different types
+		print(datalark.Int(3) == 3)
+	`, `
+		True
+		True
+		True
+		True
+		True
+		True
+		False
+		[string{"a"}, string{"b"}, string{"c"}]
+		1
+		False
+	`)
+
+	_, err := runScript(nil, "", `
+		datalark.String("a") < datalark.Int(1)
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `datalark.string < datalark.int not implemented`)
+}
+
+func TestArithmetic(t *testing.T) {
+	mustParseSchemaRunScriptAssertOutput(t, "", "", `
+		a = datalark.Int(7)
+		b = datalark.Float(2.0)
+		print(a + datalark.Int(1), a - 10, a * 3, a / 2, a // 2, a % 3)
+		print(-7 // datalark.Int(2), datalark.Int(-7) % 3)
+		print(a + b, a * b, a / b, datalark.Float(7.5) // 2, datalark.Float(7.5) % 2)
+		print(3 + a, 10 - a, 2.0 * a, 15 // a)
+		print(-a, +a, ~a, -b)
+	`, `
+		int{8} int{-3} int{21} float{3.5} int{3} int{1}
+		int{-4} int{2}
+		float{9} float{14} float{3.5} float{3} float{1.5}
+		int{10} int{3} float{14} int{2}
+		int{-7} int{7} int{-8} float{-2}
+	`)
+}
+
+func TestStringAndBytesArithmetic(t *testing.T) {
+	mustParseSchemaRunScriptAssertOutput(t, "", "", `
+		s = datalark.String("ab")
+		d = datalark.Bytes(b"\x12")
+		print(s + datalark.String("c"), s + "c", "c" + s)
+		print(s * 2, 3 * s)
+		print(d + datalark.Bytes(b"\x34"), d * 2)
+		print("b" in s, datalark.String("z") in s)
+	`, `
+		string{"abc"} string{"abc"} string{"cab"}
+		string{"abab"} string{"ababab"}
+		bytes{1234} bytes{1212}
+		True False
+	`)
+}
+
+func TestArithmeticErrors(t *testing.T) {
+	_, err := runScript(nil, "", `
+		n = datalark.Int(9223372036854775807) + 1
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `integer overflow: result of 9223372036854775807 \+ 1 does not fit in 64 bits`)
+
+	_, err = runScript(nil, "", `
+		n = datalark.Int(3) * datalark.Int(9223372036854775807)
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `integer overflow: .*`)
+
+	_, err = runScript(nil, "", `
+		n = -datalark.Int(-9223372036854775808)
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `integer overflow: result of -\-9223372036854775808 does not fit in 64 bits`)
+
+	_, err = runScript(nil, "", `
+		n = datalark.Int(3) // 0
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `floored division by zero`)
+
+	_, err = runScript(nil, "", `
+		n = datalark.Int(3) + datalark.String("a")
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `unknown binary op: int \+ string`)
+
+	_, err = runScript(nil, "", `
+		n = datalark.Int(3) + [1]
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `unknown binary op: datalark.int \+ list`)
+}
+
+func TestBytesMethods(t *testing.T) {
+	mustParseSchemaRunScriptAssertOutput(t, "", "", `
+		d = datalark.Bytes(b"\xfb\xff")
+		print(d.hex(), d.base64(), d.base64(urlsafe=True))
+		print(d.multibase("base16"), d.multibase(encoding="base64"))
+		print(datalark.Bytes(base64="-_8"), datalark.Bytes(base64="+/8="))
+		print(datalark.Bytes(hex=datalark.String("fbff")))
+		print(dir(d))
+		print([c for c in datalark.String("ab").elems()])
+	`, `
+		string{"fbff"} string{"+/8="} string{"-_8="}
+		string{"ffbff"} string{"m+/8"}
+		bytes{fbff} bytes{fbff}
+		bytes{fbff}
+		["base64", "elems", "hex", "multibase"]
+		["a", "b"]
+	`)
+
+	_, err := runScript(nil, "", `
+		d = datalark.Bytes(hex="xyz")
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `cannot decode Bytes from hex: encoding/hex: invalid byte: .*`)
+
+	_, err = runScript(nil, "", `
+		d = datalark.Bytes(base32="abc")
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `cannot decode Bytes from base32: unknown encoding "base32", expected one of hex, base64, multibase`)
+
+	_, err = runScript(nil, "", `
+		d = datalark.Bytes(hex=12)
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `cannot decode Bytes from 12 of type int, expected a string`)
+
+	_, err = runScript(nil, "", `
+		d = datalark.Bytes(b"\x01").multibase("nope")
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `multibase: .*`)
+
+	_, err = runScript(nil, "", `
+		d = datalark.Bytes(b"\x01").upper()
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `datalark.bytes has no .upper field or method`)
+}
+
+func TestStringAndBytesIndexAndSlice(t *testing.T) {
+	mustParseSchemaRunScriptAssertOutput(t, "", "", `
+		s = datalark.String("hello")
+		print(s[0], s[-1], s[1:3], s[::-1], s[10:])
+		d = datalark.Bytes(b"\x01\x02\x03")
+		print(d[0], d[-1], d[1:], d[::2])
+	`, `
+		string{"h"} string{"o"} string{"el"} string{"olleh"} string{""}
+		bytes{01} bytes{03} bytes{0203} bytes{0103}
+	`)
+
+	_, err := runScript(nil, "", `
+		s = datalark.String("hi")
+		s[2]
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `datalark.string index 2 out of range \[-2:1\]`)
+
+	// other scalars aren't sequences at all
+	_, err = runScript(nil, "", `
+		n = datalark.Int(3)
+		n[0]
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `unhandled index operation datalark.int\[int\]`)
+
+	_, err = runScript(nil, "", `
+		datalark.Int(3)[1:2]
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `invalid slice operand datalark.int`)
+
+	_, err = runScript(nil, "", `
+		datalark.Bool(True)[0]
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `unhandled index operation datalark.bool\[int\]`)
+
+	_, err = runScript(nil, "", `
+		len(datalark.Float(1.5))
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `len: value of type datalark.float has no len`)
+}
+
+func TestUnwrap(t *testing.T) {
+	mustParseSchemaRunScriptAssertOutput(t, "", "", `
+		d = {"x": 1, 3: 2}
+		print(d[datalark.unwrap(datalark.String("x"))], d[datalark.unwrap(datalark.Int(3))])
+		print(type(datalark.unwrap(datalark.Bytes(b"ab"))), datalark.unwrap(datalark.Bool(True)), datalark.unwrap(7))
+	`, `
+		1 2
+		bytes True 7
+	`)
+
+	// without unwrap, comparisons with starlark values are always False
+	mustParseSchemaRunScriptAssertOutput(t, "", "", `
+		print(datalark.String("abc") == "abc", datalark.Int(3) == 3, datalark.Int(3) != 3)
+		print(datalark.unwrap(datalark.String("abc")) == "abc", datalark.unwrap(datalark.Int(3)) == 3)
+	`, `
+		False False True
+		True True
+	`)
+
+	// and the key isn't found, because it has a different type
+	_, err := runScript(nil, "", `{"x": 1}[datalark.String("x")]`)
+	qt.Assert(t, err, qt.ErrorMatches, `key string{"x"} not in dict`)
+
+	_, err = runScript(nil, "", `datalark.unwrap(datalark.List(_=[1]))`)
+	qt.Assert(t, err, qt.ErrorMatches, `unwrap: datalark.List has no plain starlark equivalent`)
+
+	// enums are compared with strings by their name instead
+	defines := mustParseSchemaDefines(t, `
+		type Color enum {
+			| Red
+		}
+	`)
+	_, err = runScript(defines, "mytypes", `datalark.unwrap(mytypes.Color("Red"))`)
+	qt.Assert(t, err, qt.ErrorMatches, `unwrap: datalark.Enum<Color> has no plain starlark equivalent`)
+}
@@ -0,0 +1,210 @@
+package datalarkengine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/ipld/go-ipld-prime/node/bindnode"
+	"github.com/ipld/go-ipld-prime/schema"
+	"go.starlark.net/starlark"
+)
+
+// schemaFunc parses an IPLD Schema DSL document given by the script, and returns
+// an Object containing constructors for all of its types, as MakeConstructors does
+func schemaFunc(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var starDSL starlark.Value
+	name := "<script>"
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "dsl", &starDSL, "name?", &name); err != nil {
+		return starlark.None, err
+	}
+	dsl, err := textOf(starDSL)
+	if err != nil {
+		return starlark.None, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+
+	ts, err := loadSchema(name, dsl)
+	if err != nil {
+		return starlark.None, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+	obj, err := ConstructorsFromTypeSystem(ts, ConstructorOptions{})
+	if err != nil {
+		return starlark.None, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+	return obj, nil
+}
+
+// ConstructorOptions are the options for ConstructorsFromTypeSystem
+type ConstructorOptions struct {
+	// Focus is the names of the types to make constructors for. If empty, all
+	// types in the TypeSystem get constructors
+	Focus []string
+	// Referenced also makes constructors for all the types that the Focus types
+	// refer to, transitively: the types of struct fields, list and map keys and
+	// values, union members, and the targets of typed links. Types that are
+	// declared inline, such as {String:Tag}, and the prelude types, such as
+	// String, are looked through but don't get constructors of their own
+	Referenced bool
+	// Namespace nests the constructors inside Objects, one for each dot separated
+	// part of it. For example, "acme.billing" puts the constructor for Invoice
+	// at acme.billing.Invoice
+	Namespace string
+	// Bind maps type names to Go pointer types, such as (*Invoice)(nil), which
+	// the data of that type is bound to via bindnode. Types that aren't given
+	// here get Go types inferred for them
+	Bind map[string]interface{}
+}
+
+// See docs on datalark.ConstructorsFromTypeSystem.
+func ConstructorsFromTypeSystem(ts *schema.TypeSystem, opts ConstructorOptions) (*Object, error) {
+	for name := range opts.Bind {
+		if ts.TypeByName(name) == nil {
+			return nil, fmt.Errorf("cannot bind type %q, it is not in the schema", name)
+		}
+	}
+
+	names := opts.Focus
+	if len(names) == 0 {
+		names = sortedTypeNames(ts)
+	} else if opts.Referenced {
+		names = referencedTypeNames(ts, names)
+	}
+	prototypes := make([]schema.TypedPrototype, 0, len(names))
+	for _, name := range names {
+		typ := ts.TypeByName(name)
+		if typ == nil {
+			return nil, fmt.Errorf("cannot make a constructor for type %q, it is not in the schema", name)
+		}
+		npt, err := bindPrototype(opts.Bind[name], typ)
+		if err != nil {
+			return nil, err
+		}
+		prototypes = append(prototypes, npt)
+	}
+
+	obj := MakeConstructors(prototypes)
+	if opts.Namespace == "" {
+		return obj, nil
+	}
+	parts := strings.Split(opts.Namespace, ".")
+	for i := len(parts) - 1; i >= 0; i-- {
+		if parts[i] == "" {
+			return nil, fmt.Errorf("invalid namespace %q, it has an empty part", opts.Namespace)
+		}
+		outer := NewObject(1)
+		outer.SetKey(starlark.String(parts[i]), obj)
+		outer.Freeze()
+		obj = outer
+	}
+	return obj, nil
+}
+
+// loadSchema parses a schema DSL document. ipld-prime panics on some schemas
+// it can't compile, such as ones with copy types; that is returned as an error instead
+func loadSchema(name, dsl string) (ts *schema.TypeSystem, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("cannot load schema %q: %v", name, r)
+		}
+	}()
+	return ipld.LoadSchema(name, strings.NewReader(dsl))
+}
+
+// bindPrototype makes a prototype for a type using bindnode, which panics if
+// the Go type doesn't match the schema type; that is returned as an error instead
+func bindPrototype(ptrType interface{}, typ schema.Type) (npt schema.TypedPrototype, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("cannot bind type %q to %T: %v", typ.Name(), ptrType, r)
+		}
+	}()
+	return bindnode.Prototype(ptrType, typ), nil
+}
+
+// textOf returns the text of a starlark string or a datalark string
+func textOf(val starlark.Value) (string, error) {
+	switch x := val.(type) {
+	case starlark.String:
+		return string(x), nil
+	case Value:
+		if s, err := x.Node().AsString(); err == nil {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("expected a string, got %s", val.Type())
+}
+
+// sortedTypeNames returns the names of all the types in a TypeSystem, sorted,
+// so that the constructors made for them are always listed in the same order
+func sortedTypeNames(ts *schema.TypeSystem) []string {
+	types := ts.GetTypes()
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// preludeTypeNames are the types that the schema compiler adds to every TypeSystem
+var preludeTypeNames = map[string]bool{
+	"Bool": true, "Int": true, "Float": true, "String": true, "Bytes": true,
+	"Any": true, "Map": true, "List": true, "Link": true,
+}
+
+// isInlineTypeName returns whether a type was declared inline, such as
+// {String:Tag}, for which the schema compiler generates a name like "Map__String__Tag"
+func isInlineTypeName(name string) bool {
+	return strings.HasPrefix(name, "Map__") || strings.HasPrefix(name, "List__")
+}
+
+// referencedTypeNames returns the given type names, followed by the names of
+// all the types that they refer to, transitively, in the order they are found.
+// Inline and prelude types are walked through, but left out of the result.
+// Names that aren't in the TypeSystem are kept, for the caller to complain about
+func referencedTypeNames(ts *schema.TypeSystem, roots []string) []string {
+	seen := make(map[string]bool, len(roots))
+	// found is every type reached, in order, and names are the ones to return
+	found := make([]string, 0, len(roots))
+	names := make([]string, 0, len(roots))
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			found = append(found, name)
+			if !preludeTypeNames[name] && !isInlineTypeName(name) {
+				names = append(names, name)
+			}
+		}
+	}
+	for _, name := range roots {
+		if !seen[name] {
+			seen[name] = true
+			found = append(found, name)
+			names = append(names, name)
+		}
+	}
+	// found grows as types are reached, so this walks the whole closure
+	for i := 0; i < len(found); i++ {
+		switch typ := ts.TypeByName(found[i]).(type) {
+		case *schema.TypeStruct:
+			for _, field := range typ.Fields() {
+				add(field.Type().Name())
+			}
+		case *schema.TypeList:
+			add(typ.ValueType().Name())
+		case *schema.TypeMap:
+			add(typ.KeyType().Name())
+			add(typ.ValueType().Name())
+		case *schema.TypeUnion:
+			for _, member := range typ.Members() {
+				add(member.Name())
+			}
+		case *schema.TypeLink:
+			if typ.HasReferencedType() {
+				add(typ.ReferencedType().Name())
+			}
+		}
+	}
+	return names
+}
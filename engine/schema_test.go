@@ -0,0 +1,183 @@
+package datalarkengine
+
+import (
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/ipld/go-ipld-prime"
+	"github.com/ipld/go-ipld-prime/schema"
+	"go.starlark.net/starlark"
+
+	"github.com/ipld/go-datalark/testutil"
+)
+
+func TestSchemaFromScript(t *testing.T) {
+	mustParseSchemaRunScriptAssertOutput(t, "", "", `
+		s = datalark.schema("""
+			type Point struct {
+				x Int
+				y Int
+			} representation tuple
+		""")
+		p = s.Point(x=1, y=2)
+		print(p)
+		print(datalark.codec.encode(p, "dag-json"))
+		t = datalark.schema(datalark.String("type Names [String]"), name="names.ipldsch")
+		print(t.Names("a", "b"))
+	`, `
+		struct<Point>{
+			x: int<Int>{1}
+			y: int<Int>{2}
+		}
+		[1,2]
+		list<Names>{
+			0: string<String>{"a"}
+			1: string<String>{"b"}
+		}
+	`)
+}
+
+func TestSchemaFromScriptErrors(t *testing.T) {
+	_, err := runScript(nil, "", `datalark.schema("type Foo struct {")`)
+	qt.Assert(t, err, qt.ErrorMatches, "schema: .*")
+
+	_, err = runScript(nil, "", `datalark.schema("type Foo = Bar type Bar string")`)
+	qt.Assert(t, err, qt.ErrorMatches, `schema: cannot load schema "<script>": .*`)
+
+	_, err = runScript(nil, "", `datalark.schema(1)`)
+	qt.Assert(t, err, qt.ErrorMatches, "schema: expected a string, got int")
+
+	_, err = runScript(nil, "", `datalark.schema("type Foo struct {}").Bar()`)
+	qt.Assert(t, err, qt.ErrorMatches, "object has no .Bar field or method")
+}
+
+var constructorsSchema = `
+	type Invoice struct {
+		id String
+		total Int
+	}
+	type Draft [String]
+`
+
+func mustLoadSchema(t *testing.T, schemaText string) *schema.TypeSystem {
+	ts, err := ipld.LoadSchema("<noname>", strings.NewReader(schemaText))
+	qt.Assert(t, err, qt.IsNil)
+	return ts
+}
+
+// runScriptWithObject runs a script with the contents of obj injected into its globals
+func runScriptWithObject(t *testing.T, obj *Object, script string) string {
+	globals := starlark.StringDict{}
+	qt.Assert(t, InjectGlobals(globals, obj), qt.IsNil)
+	output, err := runScript(nil, "", script, withGlobals(globals))
+	qt.Assert(t, err, qt.IsNil)
+	return output
+}
+
+func TestConstructorsFromTypeSystem(t *testing.T) {
+	ts := mustLoadSchema(t, constructorsSchema)
+
+	type Invoice struct {
+		Id    string
+		Total int64
+	}
+	obj, err := ConstructorsFromTypeSystem(ts, ConstructorOptions{
+		Focus:     []string{"Invoice", "String"},
+		Namespace: "acme.billing",
+		Bind:      map[string]interface{}{"Invoice": (*Invoice)(nil)},
+	})
+	qt.Assert(t, err, qt.IsNil)
+
+	output := runScriptWithObject(t, obj, `
+		print(dir(acme), dir(acme.billing))
+		inv = acme.billing.Invoice(id="a1", total=30)
+		print(inv.total)
+	`)
+	qt.Assert(t, output, qt.Equals, testutil.Dedent(`
+		["billing"] ["Invoice", "String"]
+		int<Int>{30}
+	`))
+}
+
+func TestConstructorsFromTypeSystemAllTypes(t *testing.T) {
+	ts := mustLoadSchema(t, constructorsSchema)
+
+	obj, err := ConstructorsFromTypeSystem(ts, ConstructorOptions{})
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, obj.AttrNames(), qt.DeepEquals, []string{
+		"Any", "Bool", "Bytes", "Draft", "Float", "Int", "Invoice", "Link", "List", "Map", "String",
+	})
+}
+
+func TestConstructorsFromTypeSystemErrors(t *testing.T) {
+	ts := mustLoadSchema(t, constructorsSchema)
+
+	_, err := ConstructorsFromTypeSystem(ts, ConstructorOptions{Focus: []string{"Nope"}})
+	qt.Assert(t, err, qt.ErrorMatches, `cannot make a constructor for type "Nope", it is not in the schema`)
+
+	_, err = ConstructorsFromTypeSystem(ts, ConstructorOptions{Bind: map[string]interface{}{"Nope": (*string)(nil)}})
+	qt.Assert(t, err, qt.ErrorMatches, `cannot bind type "Nope", it is not in the schema`)
+
+	_, err = ConstructorsFromTypeSystem(ts, ConstructorOptions{Bind: map[string]interface{}{"Invoice": (*string)(nil)}})
+	qt.Assert(t, err, qt.ErrorMatches, `cannot bind type "Invoice" to \*string: .*`)
+
+	_, err = ConstructorsFromTypeSystem(ts, ConstructorOptions{Namespace: "acme..billing"})
+	qt.Assert(t, err, qt.ErrorMatches, `invalid namespace "acme..billing", it has an empty part`)
+}
+
+func TestConstructorsFromTypeSystemReferenced(t *testing.T) {
+	ts := mustLoadSchema(t, `
+		type Order struct {
+			customer Customer
+			lines [Line]
+			status Status
+			previous optional &Order
+		}
+		type Customer struct {
+			name String
+			tags {String:Tag}
+		}
+		type Tag string
+		type Line union {
+			| Product "product"
+			| Discount "discount"
+		} representation keyed
+		type Product struct {
+			sku String
+			price Int
+		}
+		type Discount int
+		type Status enum {
+			| Open
+			| Closed
+		}
+		type Unrelated struct {
+			x Float
+		}
+	`)
+
+	obj, err := ConstructorsFromTypeSystem(ts, ConstructorOptions{Focus: []string{"Customer"}, Referenced: true})
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, obj.AttrNames(), qt.DeepEquals, []string{"Customer", "Tag"})
+
+	obj, err = ConstructorsFromTypeSystem(ts, ConstructorOptions{Focus: []string{"Order"}, Referenced: true})
+	qt.Assert(t, err, qt.IsNil)
+	names := obj.AttrNames()
+	qt.Assert(t, names, qt.Not(qt.Contains), "Unrelated")
+	qt.Assert(t, names, qt.Not(qt.Contains), "Float")
+	qt.Assert(t, names, qt.Not(qt.Contains), "Int")
+	qt.Assert(t, names, qt.Not(qt.Contains), "List__Line")
+	for _, name := range []string{"Order", "Customer", "Line", "Product", "Discount", "Status", "Tag"} {
+		qt.Assert(t, names, qt.Contains, name)
+	}
+
+	output := runScriptWithObject(t, obj, `
+		c = Customer(name="ann", tags={"a": "vip"})
+		print(Order(customer=c, lines=[Line(product=Product(sku="x", price=1))], status="Open").status)
+	`)
+	qt.Assert(t, output, qt.Equals, "enum<Status>{\"Open\"}\n")
+
+	_, err = ConstructorsFromTypeSystem(ts, ConstructorOptions{Focus: []string{"Nope"}, Referenced: true})
+	qt.Assert(t, err, qt.ErrorMatches, `cannot make a constructor for type "Nope", it is not in the schema`)
+}
@@ -4,9 +4,9 @@ import (
 	"fmt"
 
 	"github.com/ipld/go-ipld-prime/datamodel"
-	"github.com/ipld/go-ipld-prime/printer"
 	"github.com/ipld/go-ipld-prime/schema"
 	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
 )
 
 type structValue struct {
@@ -23,10 +23,10 @@ func (v *structValue) Node() datamodel.Node {
 	return v.node
 }
 func (v *structValue) Type() string {
-	return fmt.Sprintf("datalark.Struct<%T>", v.node.(schema.TypedNode).Type().Name())
+	return fmt.Sprintf("datalark.Struct<%s>", typeDisplayName(v.node.(schema.TypedNode).Type()))
 }
 func (v *structValue) String() string {
-	return printer.Sprint(v.node)
+	return sprintNode(v.node)
 }
 func (v *structValue) Freeze() {}
 func (v *structValue) Truth() starlark.Bool {
@@ -55,9 +55,28 @@ func (v *structValue) Hash() (uint32, error) {
 	return x, nil
 }
 
+// starlark.Comparable
+
+// CompareSameType compares the contents of values, which can only be
+// checked for equality
+func (v *structValue) CompareSameType(op syntax.Token, y starlark.Value, depth int) (bool, error) {
+	return compareNodesEqual(op, v, y.(Value))
+}
+
 func (v *structValue) Attr(name string) (starlark.Value, error) {
 	// TODO: distinction between 'Attr' and 'Get'.  This can/should list functions, I think.  'Get' makes it unambiguous.  I think.
 	// TODO: perhaps also add a "__constr__" or "__proto__" function to everything?
+	typ := v.node.(schema.TypedNode).Type().(*schema.TypeStruct)
+	if typ.Field(name) == nil {
+		switch name {
+		case "Repr":
+			return reprView(v)
+		case "replace":
+			return starlark.NewBuiltin("replace", guardBuiltin(v.replaceMethod)), nil
+		case "with_path":
+			return withPathMethod(v), nil
+		}
+	}
 	n, err := v.node.LookupByString(name)
 	if err != nil {
 		return nil, err
@@ -65,19 +84,102 @@ func (v *structValue) Attr(name string) (starlark.Value, error) {
 	return ToValue(n)
 }
 
+// structAttrs are the attributes of every struct, other than its fields:
+// "Repr" for its representation view, and the methods for updating it
+var structAttrs = []string{"Repr", "replace", "with_path"}
+
+// AttrNames returns the names of the struct's fields, which are taken from
+// its type rather than by iterating the node, so there is nothing to fail,
+// along with the attributes that aren't hidden by a field of the same name
 func (v *structValue) AttrNames() []string {
-	names := make([]string, 0, v.node.Length())
-	for itr := v.node.MapIterator(); !itr.Done(); {
-		k, _, err := itr.Next()
-		if err != nil {
-			panic(fmt.Errorf("error while iterating: %w", err)) // should *really* not happen for structs, incidentally.
+	typ := v.node.(schema.TypedNode).Type().(*schema.TypeStruct)
+	names := make([]string, 0, len(typ.Fields())+len(structAttrs))
+	for _, f := range typ.Fields() {
+		names = append(names, f.Name())
+	}
+	for _, name := range structAttrs {
+		if typ.Field(name) == nil {
+			names = append(names, name)
 		}
-		ks, _ := k.AsString()
-		names = append(names, ks)
 	}
 	return names
 }
 
+// SetField always fails, use replace to get a copy with a field changed
 func (v *structValue) SetField(name string, val starlark.Value) error {
-	return fmt.Errorf("datalark values are immutable")
+	return fmt.Errorf("datalark values are immutable, use replace(%s=...) to get an updated copy", name)
+}
+
+// replaceMethod is the "replace" method, which returns a copy of the struct
+// with the fields given as keyword args set to new values
+func (v *structValue) replaceMethod(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if len(args) > 0 {
+		return starlark.None, fmt.Errorf("%s: fields must be given as keyword args", b.Name())
+	}
+	names := make([]string, len(kwargs))
+	vals := make([]starlark.Value, len(kwargs))
+	for i, kv := range kwargs {
+		names[i] = string(kv[0].(starlark.String))
+		vals[i] = kv[1]
+	}
+	res, err := v.withFields(names, vals)
+	if err != nil {
+		return starlark.None, fmt.Errorf("%s: %w", b.Name(), inType(err, v.typeName()))
+	}
+	return res, nil
+}
+
+// withFields returns a copy of the struct with the named fields set to new
+// values, and the rest the same. The copy is made by the struct's type-level
+// constructor, so the new values are checked just as they are when constructing
+func (v *structValue) withFields(names []string, vals []starlark.Value) (Value, error) {
+	tn := v.node.(schema.TypedNode)
+	typ := tn.Type().(*schema.TypeStruct)
+	changed := make(map[string]starlark.Value, len(names))
+	for i, name := range names {
+		changed[name] = vals[i]
+	}
+
+	argseq := &ArgSeq{}
+	addArg := func(name string, val starlark.Value) {
+		argseq.names = append(argseq.names, name)
+		argseq.keys = append(argseq.keys, starlark.String(name))
+		argseq.vals = append(argseq.vals, val)
+	}
+	for _, f := range typ.Fields() {
+		if val, ok := changed[f.Name()]; ok {
+			addArg(f.Name(), val)
+			delete(changed, f.Name())
+			continue
+		}
+		n, err := v.node.LookupByString(f.Name())
+		if err != nil {
+			return nil, err
+		}
+		if n.IsAbsent() {
+			continue
+		}
+		val, err := ToValue(n)
+		if err != nil {
+			return nil, err
+		}
+		addArg(f.Name(), val)
+	}
+	// names that aren't fields are left for the constructor to complain about
+	for _, name := range names {
+		if val, ok := changed[name]; ok {
+			addArg(name, val)
+		}
+	}
+
+	tp := tn.Prototype().(schema.TypedPrototype)
+	res, err := constructTypedValue(&Prototype{name: typ.Name(), np: tp, mode: TypedMode}, tp, argseq)
+	if err != nil {
+		return nil, err
+	}
+	return res.(Value), nil
+}
+
+func (v *structValue) typeName() string {
+	return v.node.(schema.TypedNode).Type().Name()
 }
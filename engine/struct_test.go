@@ -101,7 +101,7 @@ func TestStructWrongNumberOfFields2(t *testing.T) {
 	if err == nil {
 		t.Fatalf("expected error, did not get one")
 	}
-	expectErr := `expected 3 values (cat,dog,eel), only got 2`
+	expectErr := `missing required field "eel" of struct Animals`
 	qt.Assert(t, err.Error(), qt.Equals, expectErr)
 }
 
@@ -148,3 +148,248 @@ func TestEmptyConstructor(t *testing.T) {
 		}
 	`)
 }
+
+var fieldErrorsSchema = `
+	type Outer struct {
+		name String
+		inner Inner
+		items [Inner]
+		byKey {String:Inner}
+	}
+	type Inner struct {
+		field Int
+		other optional String
+	}
+	type Renamed struct {
+		beta String (rename "b")
+	} representation map
+`
+
+func TestStructFieldNameErrors(t *testing.T) {
+	defines := mustParseSchemaDefines(t, fieldErrorsSchema)
+
+	_, err := runScript(defines, "mytypes", `mytypes.Outer(nmae="x", inner={"field": 1}, items=[], byKey={})`)
+	qt.Assert(t, err, qt.ErrorMatches, `"nmae" is not a field of struct Outer \(did you mean "name"\?\)`)
+
+	_, err = runScript(defines, "mytypes", `mytypes.Inner(Field=1)`)
+	qt.Assert(t, err, qt.ErrorMatches, `"Field" is not a field of struct Inner \(did you mean "field"\?\)`)
+
+	_, err = runScript(defines, "mytypes", `mytypes.Outer(zzz="x", inner={"field": 1}, items=[], byKey={})`)
+	qt.Assert(t, err, qt.ErrorMatches, `"zzz" is not a field of struct Outer \(fields: name, inner, items, byKey\)`)
+
+	_, err = runScript(defines, "mytypes", `mytypes.Outer(name="x")`)
+	qt.Assert(t, err, qt.ErrorMatches, `missing required fields "inner", "items", "byKey" of struct Outer`)
+
+	_, err = runScript(defines, "mytypes", `mytypes.Inner(other="x")`)
+	qt.Assert(t, err, qt.ErrorMatches, `missing required field "field" of struct Inner`)
+}
+
+func TestStructFieldNamesWithRename(t *testing.T) {
+	defines := mustParseSchemaDefines(t, fieldErrorsSchema)
+
+	_, err := runScript(defines, "mytypes", `mytypes.Renamed(beta="x", b="y")`)
+	qt.Assert(t, err, qt.ErrorMatches, `field "beta" of struct Renamed is given more than once \(as "beta" and "b"\)`)
+
+	_, err = runScript(defines, "mytypes", `mytypes.Renamed.Typed(b="y")`)
+	qt.Assert(t, err, qt.ErrorMatches, `"b" is not a field of struct Renamed \(fields: beta\)`)
+
+	_, err = runScript(defines, "mytypes", `mytypes.Renamed.Repr(beta="y")`)
+	qt.Assert(t, err, qt.ErrorMatches, `"beta" is not a field of struct Renamed \(fields: b\)`)
+
+	output, err := runScript(defines, "mytypes", `
+		print(mytypes.Renamed(beta="x").beta)
+		print(mytypes.Renamed(b="y").beta)
+	`)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, output, qt.Equals, "string<String>{\"x\"}\nstring<String>{\"y\"}\n")
+}
+
+func TestStructErrorsHavePath(t *testing.T) {
+	defines := mustParseSchemaDefines(t, fieldErrorsSchema)
+
+	_, err := runScript(defines, "mytypes", `mytypes.Outer(name="x", inner={"field": "no"}, items=[], byKey={})`)
+	qt.Assert(t, err, qt.ErrorMatches, `Outer\.inner\.field: .*`)
+
+	_, err = runScript(defines, "mytypes", `mytypes.Outer("x", {"field": "no"}, [], {})`)
+	qt.Assert(t, err, qt.ErrorMatches, `Outer\.inner\.field: .*`)
+
+	_, err = runScript(defines, "mytypes", `mytypes.Outer(name="x", inner={}, items=[], byKey={})`)
+	qt.Assert(t, err, qt.ErrorMatches, `Outer\.inner: .*field.*`)
+
+	_, err = runScript(defines, "mytypes", `mytypes.Outer(name="x", inner={"field": 1}, items=[{"field": 1}, {"field": "no"}], byKey={})`)
+	qt.Assert(t, err, qt.ErrorMatches, `Outer\.items\.1\.field: .*`)
+
+	_, err = runScript(defines, "mytypes", `mytypes.Outer(name="x", inner={"field": 1}, items=[], byKey={"k": {"field": "no"}})`)
+	qt.Assert(t, err, qt.ErrorMatches, `Outer\.byKey\.k\.field: .*`)
+}
+
+func TestEditDistance(t *testing.T) {
+	qt.Assert(t, editDistance("", ""), qt.Equals, 0)
+	qt.Assert(t, editDistance("abc", ""), qt.Equals, 3)
+	qt.Assert(t, editDistance("kitten", "sitting"), qt.Equals, 3)
+	qt.Assert(t, closestName("nmae", []string{"name", "inner"}), qt.Equals, "name")
+	qt.Assert(t, closestName("xyz", []string{"name", "inner"}), qt.Equals, "")
+}
+
+var structReprSchema = `
+	type Point struct {
+		x Int
+		y Int
+	} representation tuple
+	type Settings struct {
+		name String (rename "n")
+		retries Int (implicit 0)
+		verbose Bool (implicit false)
+		mode String (implicit "fast")
+	} representation map
+	type Pair struct {
+		a String
+		b String
+	} representation stringjoin {
+		join ":"
+	}
+	type Wrapper struct {
+		m {String:Int}
+	}
+`
+
+func TestStructTupleRepresentation(t *testing.T) {
+	mustParseSchemaRunScriptAssertOutput(t, structReprSchema, "mytypes", `
+		print(mytypes.Point([1, 2]))
+		print(mytypes.Point((3, 4)).y)
+		print(mytypes.Point(datalark.List(_=[5, 6])).x)
+		print(mytypes.Point.Repr(7, 8).y, mytypes.Point.Repr(_=[9, 10]).y)
+		print(mytypes.Point(1, 2).Repr)
+	`, `
+		struct<Point>{
+			x: int<Int>{1}
+			y: int<Int>{2}
+		}
+		int<Int>{4}
+		int<Int>{5}
+		int<Int>{8} int<Int>{10}
+		list{
+			0: int{1}
+			1: int{2}
+		}
+	`)
+}
+
+func TestStructMapRepresentationWithImplicits(t *testing.T) {
+	mustParseSchemaRunScriptAssertOutput(t, structReprSchema, "mytypes", `
+		print(mytypes.Settings(name="a"))
+		print(mytypes.Settings(n="b", retries=5).retries)
+		print(mytypes.Settings({"n": "c", "verbose": True}).verbose)
+		print(mytypes.Settings.Typed(name="d").retries)
+		print(mytypes.Settings.Repr(_={"n": "e"}).name)
+		print(mytypes.Settings(name="f").Repr)
+		print(mytypes.Settings.Repr(datalark.Map(n="g", retries=2)).Repr)
+		print(mytypes.Settings.Repr({datalark.String("n"): "h", datalark.String("mode"): "slow"}).mode)
+	`, `
+		struct<Settings>{
+			name: string<String>{"a"}
+			retries: int<Int>{0}
+			verbose: bool<Bool>{false}
+			mode: string<String>{"fast"}
+		}
+		int<Int>{5}
+		bool<Bool>{true}
+		int<Int>{0}
+		string<String>{"e"}
+		map{
+			string{"n"}: string{"f"}
+			string{"retries"}: int{0}
+			string{"verbose"}: bool{false}
+			string{"mode"}: string{"fast"}
+		}
+		map{
+			string{"n"}: string{"g"}
+			string{"retries"}: int{2}
+			string{"verbose"}: bool{false}
+			string{"mode"}: string{"fast"}
+		}
+		string<String>{"slow"}
+	`)
+}
+
+func TestStructReprView(t *testing.T) {
+	mustParseSchemaRunScriptAssertOutput(t, structReprSchema, "mytypes", `
+		p = mytypes.Pair("a:b")
+		print(p.Repr)
+		print(dir(p))
+		print(mytypes.Settings(name="x").Repr["n"])
+		print(mytypes.Wrapper({"k": 1}).m["k"])
+	`, `
+		string{"a:b"}
+		["Repr", "a", "b", "replace", "with_path"]
+		string{"x"}
+		int<Int>{1}
+	`)
+}
+
+func TestStructRepresentationErrors(t *testing.T) {
+	defines := mustParseSchemaDefines(t, structReprSchema)
+
+	_, err := runScript(defines, "mytypes", `mytypes.Point([1])`)
+	qt.Assert(t, err, qt.ErrorMatches, `missing required fields: y`)
+
+	// the error from the representation is kept, since no one field could take the value
+	_, err = runScript(defines, "mytypes", `mytypes.Point([1, "a"])`)
+	qt.Assert(t, err, qt.ErrorMatches, `Point\.1: .*`)
+
+	_, err = runScript(defines, "mytypes", `mytypes.Settings({"n": 5})`)
+	qt.Assert(t, err, qt.ErrorMatches, `Settings\.n: .*`)
+
+	_, err = runScript(defines, "mytypes", `mytypes.Point.Repr([1, "a"])`)
+	qt.Assert(t, err, qt.ErrorMatches, `Point\.1: .*`)
+
+	_, err = runScript(defines, "mytypes", `mytypes.Settings(retries=1)`)
+	qt.Assert(t, err, qt.ErrorMatches, `missing required field "name" of struct Settings`)
+}
+
+var replaceSchema = `
+	type Person struct {
+		name String
+		age Int
+		nick optional String
+		home Address
+	}
+	type Address struct {
+		city String
+		zip String
+	}
+`
+
+func TestStructReplace(t *testing.T) {
+	mustParseSchemaRunScriptAssertOutput(t, replaceSchema, "mytypes", `
+		p = mytypes.Person(name="Ann", age=30, home={"city": "Oslo", "zip": "0150"})
+		q = p.replace(age=31, nick="A")
+		print(q.age, q.nick, q.name, q.home.city)
+		print(p.age, p.nick)
+		r = q.replace(home=q.home.replace(city="Bergen"))
+		print(r.home.city, r.home.zip, q.home.city)
+		print(p.replace() == p, q == p)
+	`, `
+		int<Int>{31} string<String>{"A"} string<String>{"Ann"} string<String>{"Oslo"}
+		int<Int>{30} absent
+		string<String>{"Bergen"} string<String>{"0150"} string<String>{"Oslo"}
+		True False
+	`)
+}
+
+func TestStructReplaceErrors(t *testing.T) {
+	defines := mustParseSchemaDefines(t, replaceSchema)
+	person := `p = mytypes.Person(name="Ann", age=30, home={"city": "Oslo", "zip": "0150"})` + "\n"
+
+	_, err := runScript(defines, "mytypes", person+`p.replace(agee=31)`)
+	qt.Assert(t, err, qt.ErrorMatches, `replace: "agee" is not a field of struct Person \(did you mean "age"\?\)`)
+
+	_, err = runScript(defines, "mytypes", person+`p.replace(age="old")`)
+	qt.Assert(t, err, qt.ErrorMatches, `replace: Person\.age: .*`)
+
+	_, err = runScript(defines, "mytypes", person+`p.replace(31)`)
+	qt.Assert(t, err, qt.ErrorMatches, `replace: fields must be given as keyword args`)
+
+	_, err = runScript(defines, "mytypes", person+`p.age = 31`)
+	qt.Assert(t, err, qt.ErrorMatches, `datalark values are immutable, use replace\(age=...\) to get an updated copy`)
+}
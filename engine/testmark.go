@@ -121,7 +121,7 @@ func testFixtureHelper(t *testing.T, dir *testmark.DirEnt, doc *testmark.Documen
 func makeTestmarkError(doc *testmark.Document, sourceName string, scriptHunk *testmark.Hunk, err error) error {
 	dh, ok := doc.HunksByName[scriptHunk.Name]
 	if !ok {
-		fmt.Errorf("error %s:<unknown>: %w", sourceName, err)
+		return fmt.Errorf("error %s:<unknown>: %w", sourceName, err)
 	}
 	// NOTE: LineStart+1 because the doc counts from 0, while text editors start at 1
 	return fmt.Errorf("error %s:%d: %w", sourceName, dh.LineStart+1, err)
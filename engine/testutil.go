@@ -10,6 +10,7 @@ import (
 	"github.com/ipfs/go-cid"
 	"github.com/ipld/go-ipld-prime"
 	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/linking"
 	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
 	"github.com/ipld/go-ipld-prime/node/bindnode"
 	"github.com/ipld/go-ipld-prime/schema"
@@ -66,9 +67,36 @@ func mustExecExample(t *testing.T, defines []schema.TypedPrototype, globalName,
 	fmt.Printf("%s", stdout)
 }
 
+// scriptOption configures the thread and globals of runScript, for scripts
+// which need more than the constructors, such as a LinkSystem
+type scriptOption func(thread *starlark.Thread, globals starlark.StringDict)
+
+// withGlobals makes more values available to the script
+func withGlobals(extra starlark.StringDict) scriptOption {
+	return func(_ *starlark.Thread, globals starlark.StringDict) {
+		for k, v := range extra {
+			globals[k] = v
+		}
+	}
+}
+
+// withLinkSystem binds a LinkSystem to the script's thread
+func withLinkSystem(lsys *linking.LinkSystem) scriptOption {
+	return func(thread *starlark.Thread, _ starlark.StringDict) {
+		SetLinkSystem(thread, lsys)
+	}
+}
+
+// withBigIntPolicy sets the BigIntPolicy of the script's thread
+func withBigIntPolicy(policy BigIntPolicy) scriptOption {
+	return func(thread *starlark.Thread, _ starlark.StringDict) {
+		SetBigIntPolicy(thread, policy)
+	}
+}
+
 // runScript evaluates the script with the given definitions bound to the given
 // global name, and returns the output and error
-func runScript(defines []schema.TypedPrototype, globalName, script string) (string, error) {
+func runScript(defines []schema.TypedPrototype, globalName, script string, opts ...scriptOption) (string, error) {
 	var buf bytes.Buffer
 
 	script = testutil.Dedent(script)
@@ -83,6 +111,9 @@ func runScript(defines []schema.TypedPrototype, globalName, script string) (stri
 			fmt.Fprintf(&buf, "%s\n", msg)
 		},
 	}
+	for _, opt := range opts {
+		opt(thread, globals)
+	}
 
 	_, err := starlark.ExecFile(thread, "thefilename.star", script, globals)
 	return buf.String(), err
@@ -4,9 +4,9 @@ import (
 	"fmt"
 
 	"github.com/ipld/go-ipld-prime/datamodel"
-	"github.com/ipld/go-ipld-prime/printer"
 	"github.com/ipld/go-ipld-prime/schema"
 	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
 )
 
 type unionValue struct {
@@ -14,6 +14,7 @@ type unionValue struct {
 }
 
 var _ Value = (*unionValue)(nil)
+var _ starlark.HasAttrs = (*unionValue)(nil)
 
 func newUnionValue(node datamodel.Node) Value {
 	return &unionValue{node}
@@ -23,10 +24,10 @@ func (v *unionValue) Node() datamodel.Node {
 	return v.node
 }
 func (v *unionValue) Type() string {
-	return fmt.Sprintf("datalark.Union<%T>", v.node.(schema.TypedNode).Type().Name())
+	return fmt.Sprintf("datalark.Union<%s>", typeDisplayName(v.node.(schema.TypedNode).Type()))
 }
 func (v *unionValue) String() string {
-	return printer.Sprint(v.node)
+	return sprintNode(v.node)
 }
 func (v *unionValue) Freeze() {}
 func (v *unionValue) Truth() starlark.Bool {
@@ -54,3 +55,116 @@ func (v *unionValue) Hash() (uint32, error) {
 	}
 	return x, nil
 }
+
+// starlark.Comparable
+
+// CompareSameType compares the contents of values, which can only be
+// checked for equality
+func (v *unionValue) CompareSameType(op syntax.Token, y starlark.Value, depth int) (bool, error) {
+	return compareNodesEqual(op, v, y.(Value))
+}
+
+// unionMethods are the attributes of every union, which come before the names
+// of its members, should a member type happen to be named the same
+var unionMethods = []string{"match", "member", "value", "with_path"}
+
+// active returns the name of the member type that the union holds, and its value
+func (v *unionValue) active() (string, datamodel.Node, error) {
+	itr := v.node.MapIterator()
+	if itr == nil || itr.Done() {
+		return "", nil, fmt.Errorf("union %s has no member", v.typeName())
+	}
+	k, n, err := itr.Next()
+	if err != nil {
+		return "", nil, err
+	}
+	name, err := k.AsString()
+	if err != nil {
+		return "", nil, err
+	}
+	return name, n, nil
+}
+
+func (v *unionValue) typeName() string {
+	return v.node.(schema.TypedNode).Type().Name()
+}
+
+// Attr returns the name of the active member as "member", and its value as
+// "value". The value can also be gotten by the member's type name, which is
+// an error if that member isn't the active one
+func (v *unionValue) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "match":
+		return starlark.NewBuiltin("match", guardBuiltin(v.matchMethod)), nil
+	case "member":
+		member, _, err := v.active()
+		if err != nil {
+			return nil, err
+		}
+		return starlark.String(member), nil
+	case "value":
+		_, n, err := v.active()
+		if err != nil {
+			return nil, err
+		}
+		return ToValue(n)
+	case "with_path":
+		return withPathMethod(v), nil
+	}
+
+	typ := v.node.(schema.TypedNode).Type().(*schema.TypeUnion)
+	for _, m := range typ.Members() {
+		if m.Name() != name {
+			continue
+		}
+		member, n, err := v.active()
+		if err != nil {
+			return nil, err
+		}
+		if member != name {
+			return nil, fmt.Errorf("union %s holds a %s, not a %s", v.typeName(), member, name)
+		}
+		return ToValue(n)
+	}
+	return nil, nil
+}
+
+func (v *unionValue) AttrNames() []string {
+	typ := v.node.(schema.TypedNode).Type().(*schema.TypeUnion)
+	names := append([]string{}, unionMethods...)
+	for _, m := range typ.Members() {
+		names = append(names, m.Name())
+	}
+	return names
+}
+
+// matchMethod calls the function that a dict gives for the active member's
+// type name, with the member's value. The key "_" gives a function for any
+// members that aren't otherwise in the dict
+func (v *unionValue) matchMethod(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var cases *starlark.Dict
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &cases); err != nil {
+		return starlark.None, err
+	}
+	member, n, err := v.active()
+	if err != nil {
+		return starlark.None, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+	fn, found, err := cases.Get(starlark.String(member))
+	if err != nil {
+		return starlark.None, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+	if !found {
+		if fn, found, err = cases.Get(starlark.String("_")); err != nil {
+			return starlark.None, fmt.Errorf("%s: %w", b.Name(), err)
+		}
+	}
+	if !found {
+		return starlark.None, fmt.Errorf("%s: no case for member %s of union %s", b.Name(), member, v.typeName())
+	}
+	val, err := ToValue(n)
+	if err != nil {
+		return starlark.None, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+	return starlark.Call(thread, fn, starlark.Tuple{val}, nil)
+}
@@ -0,0 +1,179 @@
+package datalarkengine
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+var unionAccessSchema = `
+	type Foo struct {
+		a String
+	}
+	type Bar struct {
+		b Int
+	}
+	type Keyed union {
+		| Foo "foo"
+		| Bar "bar"
+	} representation keyed
+	type Kinded union {
+		| String string
+		| Int int
+		| Foo map
+	} representation kinded
+	type Prefixed union {
+		| String "a:"
+		| Other "b:"
+	} representation stringprefix
+	type Other string
+`
+
+func TestUnionMemberAndValue(t *testing.T) {
+	mustParseSchemaRunScriptAssertOutput(t, unionAccessSchema, "mytypes", `
+		k = mytypes.Keyed(Bar=mytypes.Bar(b=3))
+		print(k.member, k.value.b, k.Bar.b)
+		n = mytypes.Kinded(7)
+		print(n.member, n.value, n.Int)
+		p = mytypes.Prefixed("b:zyx")
+		print(p.member, p.value, p.Other)
+		print(dir(k))
+	`, `
+		Bar int<Int>{3} int<Int>{3}
+		Int int<Int>{7} int<Int>{7}
+		Other string<Other>{"zyx"} string<Other>{"zyx"}
+		["Bar", "Foo", "match", "member", "value", "with_path"]
+	`)
+}
+
+func TestUnionMatch(t *testing.T) {
+	mustParseSchemaRunScriptAssertOutput(t, unionAccessSchema, "mytypes", `
+		def describe(u):
+			return u.match({
+				"String": lambda s: "name " + str(s),
+				"Int": lambda n: "number %s" % n,
+				"_": lambda v: "something else",
+			})
+		print(describe(mytypes.Kinded("x")))
+		print(describe(mytypes.Kinded(2)))
+		print(describe(mytypes.Kinded(Foo=mytypes.Foo(a="y"))))
+		print(mytypes.Keyed(Foo=mytypes.Foo(a="z")).match({"Foo": lambda f: f.a, "Bar": lambda b: b.b}))
+	`, `
+		name string<String>{"x"}
+		number int<Int>{2}
+		something else
+		string<String>{"z"}
+	`)
+}
+
+func TestUnionAccessErrors(t *testing.T) {
+	defines := mustParseSchemaDefines(t, unionAccessSchema)
+
+	_, err := runScript(defines, "mytypes", `mytypes.Keyed(Bar=mytypes.Bar(b=3)).Foo`)
+	qt.Assert(t, err, qt.ErrorMatches, `union Keyed holds a Bar, not a Foo`)
+
+	_, err = runScript(defines, "mytypes", `mytypes.Keyed(Bar=mytypes.Bar(b=3)).Baz`)
+	qt.Assert(t, err, qt.ErrorMatches, `.* has no .Baz field or method.*`)
+
+	_, err = runScript(defines, "mytypes", `mytypes.Keyed(Bar=mytypes.Bar(b=3)).match({"Foo": print})`)
+	qt.Assert(t, err, qt.ErrorMatches, `match: no case for member Bar of union Keyed`)
+
+	_, err = runScript(defines, "mytypes", `mytypes.Keyed(Bar=mytypes.Bar(b=3)).match(1)`)
+	qt.Assert(t, err, qt.ErrorMatches, `match: .*dict.*`)
+}
+
+var unionConstructSchema = `
+	type Point struct {
+		x Int
+		y Int
+	}
+	type Names [String]
+	type Name string
+	type Count int
+	type Field union {
+		| Name string
+		| Count int
+		| Point map
+		| Names list
+	} representation kinded
+	type Short string
+	type Long string
+	type Code union {
+		| Short "a"
+		| Long "ab"
+	} representation stringprefix
+	type Tagged union {
+		| Short "s:"
+		| Long "l:"
+	} representation stringprefix
+`
+
+func TestUnionConstructKindedByKind(t *testing.T) {
+	mustParseSchemaRunScriptAssertOutput(t, unionConstructSchema, "mytypes", `
+		print(mytypes.Field("ann").member)
+		print(mytypes.Field(3).member)
+		print(mytypes.Field({"x": 1, "y": 2}).Point.y)
+		print(mytypes.Field(["a", "b"]).member)
+		print(mytypes.Field(datalark.Int(4)).Count)
+		print(mytypes.Field(mytypes.Names("c")).member)
+	`, `
+		Name
+		Count
+		int<Int>{2}
+		Names
+		int<Count>{4}
+		Names
+	`)
+}
+
+func TestUnionConstructStringprefix(t *testing.T) {
+	mustParseSchemaRunScriptAssertOutput(t, unionConstructSchema, "mytypes", `
+		print(mytypes.Tagged("l:zyx"))
+		print(mytypes.Tagged.Typed("s:abc"))
+		print(mytypes.Tagged(datalark.String("s:")).Short)
+	`, `
+		union<Tagged>{string<Long>{"zyx"}}
+		union<Tagged>{string<Short>{"abc"}}
+		string<Short>{""}
+	`)
+
+	// members which aren't represented as strings can't be made from the rest of the string
+	defines := mustParseSchemaDefines(t, `
+		type Pair struct {
+			a String
+			b String
+		}
+		type Name string
+		type Thing union {
+			| Pair "p:"
+			| Name "n:"
+		} representation stringprefix
+	`)
+	_, err := runScript(defines, "mytypes", `
+		mytypes.Thing("p:x")
+	`)
+	qt.Assert(t, err, qt.ErrorMatches, `unsupported member kind: member Pair of stringprefix union Thing is represented as a map, not a string`)
+
+	mustRunScript(t, defines, "mytypes", `
+		mytypes.Thing("n:x")
+	`)
+}
+
+func TestUnionConstructErrors(t *testing.T) {
+	defines := mustParseSchemaDefines(t, unionConstructSchema)
+
+	_, err := runScript(defines, "mytypes", `mytypes.Field(1.5)`)
+	qt.Assert(t, err, qt.ErrorMatches, `union Field has no member of kind float \(members: Name \(string\), Count \(int\), Point \(map\), Names \(list\)\)`)
+
+	_, err = runScript(defines, "mytypes", `mytypes.Code("abc")`)
+	qt.Assert(t, err, qt.ErrorMatches, `"abc" is ambiguous for union Code, it could be any of: Short \("a"\), Long \("ab"\)`)
+
+	_, err = runScript(defines, "mytypes", `mytypes.Tagged("x:1")`)
+	qt.Assert(t, err, qt.ErrorMatches, `"x:1" does not start with the prefix of any member of union Tagged \(members: Short \("s:"\), Long \("l:"\)\)`)
+
+	_, err = runScript(defines, "mytypes", `mytypes.Tagged()`)
+	qt.Assert(t, err, qt.ErrorMatches, `union Tagged must be given one value, or one member by name`)
+
+	_, err = runScript(defines, "mytypes", `mytypes.Tagged(1)`)
+	qt.Assert(t, err, qt.ErrorMatches, `cannot tell which member of union Tagged to use for 1 of type int`)
+}
@@ -1,6 +1,8 @@
 package datalarkengine
 
 import (
+	"fmt"
+
 	"github.com/ipld/go-ipld-prime/node/basicnode"
 	"github.com/ipld/go-ipld-prime/schema"
 	"go.starlark.net/starlark"
@@ -9,23 +11,34 @@ import (
 // See docs on datalark.InjectGlobals.
 // Typically you should prefer using functions in the datalark package,
 // rather than their equivalents in the datalarkengine package.
-func InjectGlobals(globals starlark.StringDict, obj *Object) {
+func InjectGlobals(globals starlark.StringDict, obj *Object) error {
 	// Technically this would work on any 'starlark.IterableMapping', but I don't think that makes the function more useful, and would make it *less* self-documenting.
 	itr := obj.Iterate()
 	defer itr.Done()
 	var k starlark.Value
 	for itr.Next(&k) {
+		name, ok := k.(starlark.String)
+		if !ok {
+			return fmt.Errorf("cannot inject global with key %v of type %s, must be a string", k, k.Type())
+		}
 		v, _, err := obj.Get(k)
 		if err != nil {
-			panic(err)
+			return err
 		}
-		globals[string(k.(starlark.String))] = v
+		globals[string(name)] = v
 	}
+	return nil
 }
 
-// PrimitiveConstructors returns the constructors for primitive types as an Object
+// PrimitiveConstructors returns the constructors for primitive types as an Object,
+// along with the "store" function for storing values using a LinkSystem,
+// the "codec" namespace of encoding and decoding functions,
+// the "schema" function for getting constructors from schema DSL,
+// the "repr" function for getting the representation view of a value,
+// the "unwrap" function for getting plain starlark values,
+// and the "bigint" function for reading ints stored by a BigIntPolicy
 func PrimitiveConstructors() *Object {
-	obj := NewObject(7)
+	obj := NewObject(14)
 	obj.SetKey(starlark.String("Map"), &Prototype{"Map", basicnode.Prototype.Map, AnyMode})
 	obj.SetKey(starlark.String("List"), &Prototype{"List", basicnode.Prototype.List, AnyMode})
 	obj.SetKey(starlark.String("Bool"), &Prototype{"Bool", basicnode.Prototype.Bool, AnyMode})
@@ -33,6 +46,13 @@ func PrimitiveConstructors() *Object {
 	obj.SetKey(starlark.String("Float"), &Prototype{"Float", basicnode.Prototype.Float, AnyMode})
 	obj.SetKey(starlark.String("String"), &Prototype{"String", basicnode.Prototype.String, AnyMode})
 	obj.SetKey(starlark.String("Bytes"), &Prototype{"Bytes", basicnode.Prototype.Bytes, AnyMode})
+	obj.SetKey(starlark.String("Link"), &Prototype{"Link", basicnode.Prototype.Link, AnyMode})
+	obj.SetKey(starlark.String("store"), starlark.NewBuiltin("store", guardBuiltin(storeFunc)))
+	obj.SetKey(starlark.String("codec"), CodecFunctions())
+	obj.SetKey(starlark.String("schema"), starlark.NewBuiltin("schema", schemaFunc))
+	obj.SetKey(starlark.String("repr"), starlark.NewBuiltin("repr", reprFunc))
+	obj.SetKey(starlark.String("unwrap"), starlark.NewBuiltin("unwrap", unwrapFunc))
+	obj.SetKey(starlark.String("bigint"), starlark.NewBuiltin("bigint", bigIntFunc))
 	obj.Freeze()
 	return obj
 }
@@ -1,12 +1,17 @@
 package datalarkengine
 
 import (
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"math"
+	"strings"
 
 	"github.com/ipld/go-ipld-prime/datamodel"
 	"github.com/ipld/go-ipld-prime/node/basicnode"
 	"github.com/ipld/go-ipld-prime/printer"
 	"github.com/ipld/go-ipld-prime/schema"
+	"github.com/multiformats/go-multibase"
 
 	"go.starlark.net/starlark"
 	"go.starlark.net/syntax"
@@ -25,20 +30,47 @@ type basicValue struct {
 
 var _ Value = (*basicValue)(nil)
 var _ starlark.HasBinary = (*basicValue)(nil)
-var _ starlark.Sequence = (*basicValue)(nil)
 var _ starlark.HasAttrs = (*basicValue)(nil)
+var _ starlark.Comparable = (*basicValue)(nil)
+var _ starlark.HasUnary = (*basicValue)(nil)
+
+// sequenceValue is a basicValue of one of the kinds that are sequences, strings
+// and bytes, which can also be indexed and sliced, like starlark's own. The other
+// kinds don't implement those interfaces at all, so starlark reports indexing
+// them as an error. The starlark equivalent is read when the value is made, so
+// that indexing, which can't return an error, has nothing left to fail
+type sequenceValue struct {
+	basicValue
+	seq starlark.Sliceable
+}
+
+var _ Value = (*sequenceValue)(nil)
+var _ starlark.Sequence = (*sequenceValue)(nil)
+var _ starlark.Sliceable = (*sequenceValue)(nil)
+
+// newBasicValue wraps a node of one of the basic kinds. The kind is taken from
+// the node, so callers such as ToValue must only pass nodes of those kinds
+func newBasicValue(node datamodel.Node) Value {
+	v := basicValue{node, node.Kind()}
+	switch v.kind {
+	case datamodel.Kind_String, datamodel.Kind_Bytes:
+		if starVal, err := v.toStarlark(); err == nil {
+			return &sequenceValue{v, starVal.(starlark.Sliceable)}
+		}
+	}
+	return &v
+}
 
-func newBasicValue(node datamodel.Node, kind datamodel.Kind) Value {
-	if kind != datamodel.Kind_Null &&
-		kind != datamodel.Kind_Bool &&
-		kind != datamodel.Kind_Int &&
-		kind != datamodel.Kind_Float &&
-		kind != datamodel.Kind_String &&
-		kind != datamodel.Kind_Bytes &&
-		kind != datamodel.Kind_Link {
-		panic(fmt.Sprintf("invalid kind for basic value: %v", kind))
+// asBasic returns the basicValue of a value, if it is one, which may be
+// either a plain basicValue or a sequenceValue
+func asBasic(val starlark.Value) (*basicValue, bool) {
+	switch it := val.(type) {
+	case *basicValue:
+		return it, true
+	case *sequenceValue:
+		return &it.basicValue, true
 	}
-	return &basicValue{node, kind}
+	return nil, false
 }
 
 func (v *basicValue) Node() datamodel.Node {
@@ -47,13 +79,38 @@ func (v *basicValue) Node() datamodel.Node {
 
 func (v *basicValue) Type() string {
 	if typed, ok := v.node.(schema.TypedNode); ok {
-		return fmt.Sprintf("datalark.%s<%T>", v.kind, typed.Type().Name())
+		return fmt.Sprintf("datalark.%s<%s>", v.kind, typeDisplayName(typed.Type()))
 	}
 	return fmt.Sprintf("datalark.%s", v.kind)
 }
 
+// typeDisplayName gets the name of a schema type, as it appears in the Type of a value.
+// Lists and maps which are written inline in a schema don't have a name of
+// their own (the schema compiler generates one, like "List__Foo"), so they are
+// shown using the schema syntax instead, like "[Foo]".
+func typeDisplayName(t schema.Type) string {
+	switch it := t.(type) {
+	case *schema.TypeList:
+		if it.IsAnonymous() || strings.HasPrefix(it.Name(), "List__") {
+			return fmt.Sprintf("[%s%s]", nullablePrefix(it.ValueIsNullable()), typeDisplayName(it.ValueType()))
+		}
+	case *schema.TypeMap:
+		if it.IsAnonymous() || strings.HasPrefix(it.Name(), "Map__") {
+			return fmt.Sprintf("{%s:%s%s}", typeDisplayName(it.KeyType()), nullablePrefix(it.ValueIsNullable()), typeDisplayName(it.ValueType()))
+		}
+	}
+	return t.Name()
+}
+
+func nullablePrefix(nullable bool) string {
+	if nullable {
+		return "nullable "
+	}
+	return ""
+}
+
 func (v *basicValue) String() string {
-	return printer.Sprint(v.node)
+	return sprintNode(v.node)
 }
 
 func (v *basicValue) Freeze() {}
@@ -62,159 +119,415 @@ func (v *basicValue) Truth() starlark.Bool {
 	return true
 }
 
+// Hash returns the same hash as the equivalent starlark value.
+//
+// Datalark values can't be used as dict keys interchangeably with starlark
+// values, such as `{datalark.String("x"): 1}["x"]`: starlark's dicts only
+// check whether keys are equal when they have the same type, and there is no
+// way for a value to change that. Scripts use datalark.unwrap to get the plain
+// starlark value for such keys instead
 func (v *basicValue) Hash() (uint32, error) {
-	// TODO(dustmop): implement me
-	return 0, nil
+	starVal, err := v.toStarlark()
+	if err != nil {
+		return 0, err
+	}
+	return starVal.Hash()
 }
 
-// constructors for convenience, each returns a datalark Value or panics upon error
+// starlark.Comparable
+
+// CompareSameType compares basic values the same way that starlark compares
+// the equivalent starlark values, so ints and floats may be compared with
+// each other, and strings, bytes, and numbers can be ordered.
+//
+// Only datalark values can be compared with each other. Starlark doesn't ask
+// values of different types to compare themselves, so `datalark.Int(3) == 3`
+// is always False, and can't be made otherwise. Scripts compare with starlark
+// values through datalark.unwrap instead, as in `datalark.unwrap(x) == 3`
+func (v *basicValue) CompareSameType(op syntax.Token, y starlark.Value, depth int) (bool, error) {
+	left, err := v.toStarlark()
+	if err != nil {
+		return false, err
+	}
+	other, _ := asBasic(y)
+	right, err := other.toStarlark()
+	if err != nil {
+		return false, err
+	}
+	res, err := starlark.CompareDepth(op, left, right, depth)
+	if err != nil {
+		// report the error using the datalark types
+		return false, fmt.Errorf("%s %s %s not implemented", v.Type(), op, y.Type())
+	}
+	return res, nil
+}
 
-// NewNull constructs a null Value
-func NewNull() Value {
+// toStarlark converts the basic value to the equivalent starlark value
+func (v *basicValue) toStarlark() (starlark.Value, error) {
+	switch v.kind {
+	case datamodel.Kind_Null:
+		return starlark.None, nil
+	case datamodel.Kind_Bool:
+		b, err := v.node.AsBool()
+		return starlark.Bool(b), err
+	case datamodel.Kind_Int:
+		i, err := v.node.AsInt()
+		return starlark.MakeInt64(i), err
+	case datamodel.Kind_Float:
+		f, err := v.node.AsFloat()
+		return starlark.Float(f), err
+	case datamodel.Kind_String:
+		s, err := v.node.AsString()
+		return starlark.String(s), err
+	case datamodel.Kind_Bytes:
+		d, err := v.node.AsBytes()
+		return starlark.Bytes(d), err
+	}
+	return nil, fmt.Errorf("cannot convert %s to a starlark value", v.Type())
+}
+
+// sprintNode formats a node in the style of the ipld printer (see
+// nodePrinter). If a node panics while being read, it falls back to
+// printing an untyped copy of the node instead
+func sprintNode(n datamodel.Node) (text string) {
+	defer func() {
+		if r := recover(); r != nil {
+			text = sprintUntyped(n)
+		}
+	}()
+	var buf strings.Builder
+	p := nodePrinter{w: &buf}
+	p.print(0, printNormal, n)
+	return buf.String()
+}
+
+func sprintUntyped(n datamodel.Node) (text string) {
+	defer func() {
+		if r := recover(); r != nil {
+			text = fmt.Sprintf("<unprintable %s>", n.Kind())
+		}
+	}()
+	copied, err := untypedCopy(n)
+	if err != nil {
+		return fmt.Sprintf("<unprintable %s: %s>", n.Kind(), err)
+	}
+	return printer.Sprint(copied)
+}
+
+// reprView returns the representation view of a value, such as a map with
+// renamed keys, or the list for a tuple struct, as it would be serialized.
+// It's copied into plain data, so it doesn't depend on how the representation
+// node iterates. Values that aren't typed are their own representation
+func reprView(v Value) (Value, error) {
+	tn, ok := v.Node().(schema.TypedNode)
+	if !ok {
+		return v, nil
+	}
+	n, err := untypedCopy(tn.Representation())
+	if err != nil {
+		return nil, err
+	}
+	return ToValue(n)
+}
+
+// reprFunc is the "repr" function, which returns the representation view of a value
+func reprFunc(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var starVal starlark.Value
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &starVal); err != nil {
+		return starlark.None, err
+	}
+	v, ok := starVal.(Value)
+	if !ok {
+		// plain starlark data is already at the representation level
+		return starVal, nil
+	}
+	rv, err := reprView(v)
+	if err != nil {
+		return starlark.None, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+	return rv, nil
+}
+
+// unwrapFunc is the "unwrap" function, which returns the plain starlark value
+// for a basic datalark value, such as a starlark string for a datalark string.
+// This is how scripts use datalark values where starlark needs its own values,
+// such as for dict keys, or comparing with starlark values. Starlark values
+// are returned as is
+func unwrapFunc(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var starVal starlark.Value
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &starVal); err != nil {
+		return starlark.None, err
+	}
+	if v, ok := asBasic(starVal); ok {
+		res, err := v.toStarlark()
+		if err != nil {
+			return starlark.None, fmt.Errorf("%s: %w", b.Name(), err)
+		}
+		return res, nil
+	}
+	if v, ok := starVal.(Value); ok {
+		return starlark.None, fmt.Errorf("%s: %s has no plain starlark equivalent", b.Name(), v.Type())
+	}
+	return starVal, nil
+}
+
+// untypedCopy deeply copies a node into basicnodes, reading each scalar
+// through its data model kind, so typed nodes such as enums become plain
+// strings. datamodel.Copy can't be used, because it keeps nested nodes as-is
+func untypedCopy(n datamodel.Node) (datamodel.Node, error) {
+	switch n.Kind() {
+	case datamodel.Kind_Map:
+		nb := basicnode.Prototype.Map.NewBuilder()
+		ma, err := nb.BeginMap(n.Length())
+		if err != nil {
+			return nil, err
+		}
+		for itr := n.MapIterator(); !itr.Done(); {
+			k, v, err := itr.Next()
+			if err != nil {
+				return nil, err
+			}
+			name, err := mapKeyString(k)
+			if err != nil {
+				return nil, err
+			}
+			cv, err := untypedCopy(v)
+			if err != nil {
+				return nil, err
+			}
+			if err := ma.AssembleKey().AssignString(name); err != nil {
+				return nil, err
+			}
+			if err := ma.AssembleValue().AssignNode(cv); err != nil {
+				return nil, err
+			}
+		}
+		if err := ma.Finish(); err != nil {
+			return nil, err
+		}
+		return nb.Build(), nil
+	case datamodel.Kind_List:
+		nb := basicnode.Prototype.List.NewBuilder()
+		la, err := nb.BeginList(n.Length())
+		if err != nil {
+			return nil, err
+		}
+		for itr := n.ListIterator(); !itr.Done(); {
+			_, v, err := itr.Next()
+			if err != nil {
+				return nil, err
+			}
+			cv, err := untypedCopy(v)
+			if err != nil {
+				return nil, err
+			}
+			if err := la.AssembleValue().AssignNode(cv); err != nil {
+				return nil, err
+			}
+		}
+		if err := la.Finish(); err != nil {
+			return nil, err
+		}
+		return nb.Build(), nil
+	}
 	nb := basicnode.Prototype.Any.NewBuilder()
-	if err := nb.AssignNull(); err != nil {
-		panic(err)
+	if err := datamodel.Copy(n, nb); err != nil {
+		return nil, err
 	}
-	return newBasicValue(nb.Build(), datamodel.Kind_Null)
+	return nb.Build(), nil
+}
+
+// compareNodesEqual compares nodes, for values which only support equality
+func compareNodesEqual(op syntax.Token, x, y Value) (bool, error) {
+	switch op {
+	case syntax.EQL:
+		return datamodel.DeepEqual(x.Node(), y.Node()), nil
+	case syntax.NEQ:
+		return !datamodel.DeepEqual(x.Node(), y.Node()), nil
+	}
+	return false, fmt.Errorf("%s %s %s not implemented", x.Type(), op, y.Type())
+}
+
+// constructors for convenience, each returns a datalark Value
+
+// NewNull constructs a null Value
+func NewNull() Value {
+	return newBasicValue(datamodel.Null)
 }
 
 // NewBool constructs a bool Value
 func NewBool(b bool) Value {
-	nb := basicnode.Prototype.Bool.NewBuilder()
-	if err := nb.AssignBool(b); err != nil {
-		panic(err)
-	}
-	return newBasicValue(nb.Build(), datamodel.Kind_Bool)
+	return newBasicValue(basicnode.NewBool(b))
 }
 
 // NewInt constructs a int Value
 func NewInt(n int64) Value {
-	nb := basicnode.Prototype.Int.NewBuilder()
-	if err := nb.AssignInt(n); err != nil {
-		panic(err)
-	}
-	return newBasicValue(nb.Build(), datamodel.Kind_Int)
+	return newBasicValue(basicnode.NewInt(n))
 }
 
 // NewFloat constructs a float Value
 func NewFloat(f float64) Value {
-	nb := basicnode.Prototype.Float.NewBuilder()
-	if err := nb.AssignFloat(f); err != nil {
-		panic(err)
-	}
-	return newBasicValue(nb.Build(), datamodel.Kind_Float)
+	return newBasicValue(basicnode.NewFloat(f))
 }
 
 // NewString constructs a string Value
 func NewString(text string) Value {
-	nb := basicnode.Prototype.String.NewBuilder()
-	if err := nb.AssignString(text); err != nil {
-		panic(err)
-	}
-	return newBasicValue(nb.Build(), datamodel.Kind_String)
+	return newBasicValue(basicnode.NewString(text))
 }
 
 // NewBytes constructs a bytes Value
 func NewBytes(d []byte) Value {
-	nb := basicnode.Prototype.Bytes.NewBuilder()
-	if err := nb.AssignBytes(d); err != nil {
-		panic(err)
-	}
-	return newBasicValue(nb.Build(), datamodel.Kind_Bytes)
-}
-
-// NewBytes constructs a Link Value
-func NewLink(x datamodel.Link) Value {
-	nb := basicnode.Prototype.Link.NewBuilder()
-	if err := nb.AssignLink(x); err != nil {
-		panic(err)
-	}
-	return newBasicValue(nb.Build(), datamodel.Kind_Link)
+	return newBasicValue(basicnode.NewBytes(d))
 }
 
 // starlark.HasBinary
 
+// Binary implements arithmetic on numbers, and concatenation and repetition of
+// strings and bytes, with the same semantics as starlark's own values. The other
+// operand may be either a datalark value or a starlark value. The result is a
+// datalark value, so ints which don't fit in 64 bits are an error.
 func (v *basicValue) Binary(op syntax.Token, y starlark.Value, side starlark.Side) (starlark.Value, error) {
-	if op == syntax.PLUS || op == syntax.MINUS || op == syntax.STAR || op == syntax.SLASH {
-		if other, ok := y.(*basicValue); ok {
-			return v.binaryBasicOp(op, other)
-		}
-		if _, ok := y.(starlark.Int); ok {
-			num, err := starlark.AsInt32(y)
-			if err != nil {
-				return starlark.None, err
-			}
-			return v.binaryBasicOp(op, NewInt(int64(num)).(*basicValue))
+	left, err := v.toStarlark()
+	if err != nil {
+		return nil, err
+	}
+	var right starlark.Value
+	if other, ok := asBasic(y); ok {
+		if right, err = other.toStarlark(); err != nil {
+			return nil, err
 		}
-		if _, ok := y.(starlark.Float); ok {
-			f, ok := starlark.AsFloat(y)
-			if !ok {
-				return starlark.None, fmt.Errorf("could not convert %s to float", y)
-			}
-			return v.binaryBasicOp(op, NewFloat(float64(f)).(*basicValue))
+	} else {
+		switch y.(type) {
+		case starlark.Int, starlark.Float, starlark.String, starlark.Bytes, starlark.Bool:
+			right = y
+		default:
+			// unsupported, let starlark report the error
+			return nil, nil
 		}
 	}
-	return starlark.None, fmt.Errorf("cannot %T %s %T", v, op, y)
+	if side == starlark.Right {
+		left, right = right, left
+	}
+
+	res, err := bytesBinary(op, left, right)
+	if res == nil && err == nil {
+		res, err = starlark.Binary(op, left, right)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return starlarkToBasic(res, func() string {
+		return fmt.Sprintf("%s %s %s", left, op, right)
+	})
 }
 
-func (v *basicValue) binaryBasicOp(op syntax.Token, other *basicValue) (starlark.Value, error) {
-	if v.kind == datamodel.Kind_Int && other.kind == datamodel.Kind_Int {
-		left, err := v.node.AsInt()
-		if err != nil {
-			return starlark.None, err
+// bytesBinary implements concatenation and repetition of bytes, which this
+// version of starlark does not support itself. Returns nil for any other operation.
+func bytesBinary(op syntax.Token, x, y starlark.Value) (starlark.Value, error) {
+	switch op {
+	case syntax.PLUS:
+		a, aok := x.(starlark.Bytes)
+		b, bok := y.(starlark.Bytes)
+		if aok && bok {
+			return a + b, nil
 		}
-		rite, err := other.node.AsInt()
-		if err != nil {
-			return starlark.None, err
+	case syntax.STAR:
+		b, ok := x.(starlark.Bytes)
+		n, nok := y.(starlark.Int)
+		if !ok {
+			b, ok = y.(starlark.Bytes)
+			n, nok = x.(starlark.Int)
 		}
-		if op == syntax.PLUS {
-			return NewInt(left + rite), nil
-		} else if op == syntax.MINUS {
-			return NewInt(left - rite), nil
-		} else if op == syntax.STAR {
-			return NewInt(left * rite), nil
-		} else if op == syntax.SLASH {
-			return NewInt(left / rite), nil
+		if ok && nok {
+			times, err := starlark.AsInt32(n)
+			if err != nil {
+				return nil, fmt.Errorf("bytes repetition count %s is too large", n)
+			}
+			if times < 1 {
+				return starlark.Bytes(""), nil
+			}
+			if int64(len(b))*int64(times) > math.MaxInt32 {
+				return nil, fmt.Errorf("bytes repetition of %d bytes by %d is too large", len(b), times)
+			}
+			return starlark.Bytes(strings.Repeat(string(b), times)), nil
 		}
 	}
-	if v.kind == datamodel.Kind_Float && other.kind == datamodel.Kind_Float {
-		left, err := v.node.AsFloat()
-		if err != nil {
-			return starlark.None, err
-		}
-		rite, err := other.node.AsFloat()
-		if err != nil {
-			return starlark.None, err
-		}
-		if op == syntax.PLUS {
-			return NewFloat(left + rite), nil
-		} else if op == syntax.MINUS {
-			return NewFloat(left - rite), nil
-		} else if op == syntax.STAR {
-			return NewFloat(left * rite), nil
-		} else if op == syntax.SLASH {
-			return NewFloat(left / rite), nil
+	return nil, nil
+}
+
+// starlark.HasUnary
+
+// Unary implements the unary operators for numbers: +, -, and ~ (ints only)
+func (v *basicValue) Unary(op syntax.Token) (starlark.Value, error) {
+	operand, err := v.toStarlark()
+	if err != nil {
+		return nil, err
+	}
+	res, err := starlark.Unary(op, operand)
+	if err != nil {
+		return nil, err
+	}
+	return starlarkToBasic(res, func() string {
+		return fmt.Sprintf("%s%s", op, operand)
+	})
+}
+
+// starlarkToBasic converts the result of a starlark operation to a datalark value.
+// Results which aren't basic values, like the bool from an "in" operation, are
+// returned as they are.
+func starlarkToBasic(res starlark.Value, describe func() string) (starlark.Value, error) {
+	switch it := res.(type) {
+	case starlark.Int:
+		n, ok := it.Int64()
+		if !ok {
+			return nil, fmt.Errorf("integer overflow: result of %s does not fit in 64 bits", describe())
 		}
+		return NewInt(n), nil
+	case starlark.Float:
+		return NewFloat(float64(it)), nil
+	case starlark.String:
+		return NewString(string(it)), nil
+	case starlark.Bytes:
+		return NewBytes([]byte(it)), nil
 	}
-	return starlark.None, fmt.Errorf("cannot apply op %s to %T and %T", op, v, other)
+	return res, nil
 }
 
 // starlark.Sequence
 
-func (v *basicValue) Iterate() starlark.Iterator {
-	panic(fmt.Errorf("TODO(dustmop): basicValue.Iterate not implemented for %T", v))
+// Iterate returns nil, because strings and bytes aren't iterable. This is the
+// same as starlark's own strings and bytes, which must use a method such as
+// elems() to get something iterable; starlark reports "not iterable" to the
+// script when it sees the nil.
+func (v *sequenceValue) Iterate() starlark.Iterator {
+	return nil
 }
 
-func (v *basicValue) Len() int {
-	switch v.kind {
-	case datamodel.Kind_String:
-		str, _ := v.node.AsString()
-		return len(str)
-	case datamodel.Kind_Bytes:
-		bytes, _ := v.node.AsBytes()
-		return len(bytes)
+func (v *sequenceValue) Len() int {
+	return v.seq.Len()
+}
+
+// starlark.Indexable
+
+// Index returns the element at index i of a string or bytes, which like
+// starlark's own values, is a string or bytes of length one
+func (v *sequenceValue) Index(i int) starlark.Value {
+	return starlarkToSequence(v.seq.Index(i))
+}
+
+// starlark.Sliceable
+
+func (v *sequenceValue) Slice(start, end, step int) starlark.Value {
+	return starlarkToSequence(v.seq.Slice(start, end, step))
+}
+
+// starlarkToSequence converts a string or bytes from indexing or slicing the
+// starlark equivalent of a sequenceValue back into a datalark value
+func starlarkToSequence(res starlark.Value) starlark.Value {
+	if str, ok := res.(starlark.String); ok {
+		return NewString(string(str))
 	}
-	return -1
+	return NewBytes([]byte(res.(starlark.Bytes)))
 }
 
 // starlark.HasAttrs
@@ -223,6 +536,8 @@ func (v *basicValue) Attr(name string) (starlark.Value, error) {
 	switch v.kind {
 	case datamodel.Kind_String:
 		return v.stringMethodCall(name)
+	case datamodel.Kind_Bytes:
+		return v.bytesMethodCall(name)
 	}
 	return starlark.None, fmt.Errorf("no methods found for %T", v)
 }
@@ -231,6 +546,8 @@ func (v *basicValue) AttrNames() []string {
 	switch v.kind {
 	case datamodel.Kind_String:
 		return v.stringMethodNames()
+	case datamodel.Kind_Bytes:
+		return v.bytesMethodNames()
 	}
 	return nil
 }
@@ -249,23 +566,118 @@ func (v *basicValue) stringMethodCall(name string) (starlark.Value, error) {
 		return starlark.None, err
 	}
 	starString := starlark.String(gstr)
-	// get the method and return it
-	starMethod := func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
-		// get actual method from underlying starlark.String
-		starMethod, err := starString.Attr(name)
-		if err != nil {
-			return starlark.None, err
-		}
+	// get actual method from underlying starlark.String, if there is
+	// no such method, starlark reports the error using our type
+	starMethod, err := starString.Attr(name)
+	if err != nil || starMethod == nil {
+		return nil, err
+	}
+	// wrap the method and return it
+	method := func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 		// call the method, and convert the result to a hosted datalark.Value
 		starRes, err := starlark.Call(thread, starMethod, args, kwargs)
 		if err != nil {
 			return starlark.None, err
 		}
-		return starToHost(starRes)
+		return methodResultToHost(name, starRes)
 	}
-	return starlark.NewBuiltin(name, starMethod), nil
+	return starlark.NewBuiltin(name, method), nil
 }
 
 func (v *basicValue) stringMethodNames() []string {
 	return stringMethods
 }
+
+// methodResultToHost converts the result of a delegated starlark method to a
+// datalark.Value. The exception is elems(), which returns starlark's own iterable
+// view that has no equivalent in the data model, so it is returned as is
+func methodResultToHost(name string, starRes starlark.Value) (starlark.Value, error) {
+	if name == "elems" {
+		return starRes, nil
+	}
+	return starToHost(starRes)
+}
+
+// starlark.Bytes methods, plus encoding helpers
+
+var bytesMethods = []string{"base64", "elems", "hex", "multibase"}
+
+func (v *basicValue) bytesMethodCall(name string) (starlark.Value, error) {
+	data, err := v.node.AsBytes()
+	if err != nil {
+		return starlark.None, err
+	}
+	var method func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error)
+	switch name {
+	case "hex":
+		method = func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			if err := starlark.UnpackPositionalArgs(name, args, kwargs, 0); err != nil {
+				return starlark.None, err
+			}
+			return NewString(hex.EncodeToString(data)), nil
+		}
+	case "base64":
+		method = func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			var urlsafe bool
+			if err := starlark.UnpackArgs(name, args, kwargs, "urlsafe?", &urlsafe); err != nil {
+				return starlark.None, err
+			}
+			if urlsafe {
+				return NewString(base64.URLEncoding.EncodeToString(data)), nil
+			}
+			return NewString(base64.StdEncoding.EncodeToString(data)), nil
+		}
+	case "multibase":
+		method = func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			encoding := "base32"
+			if err := starlark.UnpackArgs(name, args, kwargs, "encoding?", &encoding); err != nil {
+				return starlark.None, err
+			}
+			enc, err := multibase.EncoderByName(encoding)
+			if err != nil {
+				return starlark.None, fmt.Errorf("multibase: %w", err)
+			}
+			return NewString(enc.Encode(data)), nil
+		}
+	default:
+		// everything else is delegated to the underlying starlark.Bytes
+		starBytes := starlark.Bytes(data)
+		starMethod, err := starBytes.Attr(name)
+		if err != nil || starMethod == nil {
+			return nil, err
+		}
+		method = func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			starRes, err := starlark.Call(thread, starMethod, args, kwargs)
+			if err != nil {
+				return starlark.None, err
+			}
+			return methodResultToHost(name, starRes)
+		}
+	}
+	return starlark.NewBuiltin(name, method), nil
+}
+
+func (v *basicValue) bytesMethodNames() []string {
+	return bytesMethods
+}
+
+// decodeBytes decodes the text of a string into bytes, using the named encoding.
+// Used by the Bytes prototype, as in `datalark.Bytes(hex="1256")`
+func decodeBytes(encoding string, text string) ([]byte, error) {
+	switch encoding {
+	case "hex":
+		return hex.DecodeString(text)
+	case "base64":
+		// accept both the standard and url-safe alphabets, with or without padding
+		for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+			if data, err := enc.DecodeString(text); err == nil {
+				return data, nil
+			}
+		}
+		return nil, fmt.Errorf("illegal base64 data %q", text)
+	case "multibase":
+		_, data, err := multibase.Decode(text)
+		return data, err
+	}
+	return nil, fmt.Errorf("unknown encoding %q, expected one of hex, base64, multibase", encoding)
+}
@@ -10,6 +10,7 @@ import (
 	"go.starlark.net/starlark"
 
 	"github.com/ipld/go-datalark"
+	"github.com/ipld/go-datalark/engine"
 	"github.com/ipld/go-datalark/testutil"
 )
 
@@ -111,7 +112,9 @@ func Example_helloGlobals() {
 	// Note the use of 'InjectGlobals' here -- this puts things into scope without any namespace,
 	// as opposed to what we did in other examples, which let you choose a name in the globals to put everything under.
 	globals := starlark.StringDict{}
-	datalark.InjectGlobals(globals, datalark.PrimitiveConstructors())
+	if err := datalark.InjectGlobals(globals, datalark.PrimitiveConstructors()); err != nil {
+		panic(err)
+	}
 
 	// Now here's our demo script:
 	script := testutil.Dedent(`
@@ -127,3 +130,62 @@ func Example_helloGlobals() {
 	// Output:
 	// string{"yo"}
 }
+
+func Example_loadSchema() {
+	// In this example, datalark does the work of turning a schema into constructors,
+	// including binding a type to a golang native type, and putting them under a namespace.
+	typesystem, err := datalark.LoadSchema("example.ipldsch", strings.NewReader(`
+		type FooBar struct {
+			foo String
+			bar String
+		}
+		type Unused [String]
+	`))
+	if err != nil {
+		panic(err)
+	}
+
+	type FooBar struct{ Foo, Bar string }
+
+	constructors, err := datalark.ConstructorsFromTypeSystem(typesystem, datalarkengine.ConstructorOptions{
+		Focus:     []string{"FooBar"},
+		Namespace: "mytypes",
+		Bind:      map[string]interface{}{"FooBar": (*FooBar)(nil)},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	// Prepare things needed by a starlark interpreter.  (This is Starlark boilerplate!)
+	thread := &starlark.Thread{
+		Name: "thethreadname",
+		Print: func(thread *starlark.Thread, msg string) {
+			fmt.Printf("%s\n", msg)
+		},
+	}
+
+	// The namespace means that injecting the constructors puts them all under "mytypes".
+	globals := starlark.StringDict{}
+	if err := datalark.InjectGlobals(globals, constructors); err != nil {
+		panic(err)
+	}
+
+	// Now here's our demo script:
+	script := testutil.Dedent(`
+		print(dir(mytypes))
+		print(mytypes.FooBar(foo="helloooo", bar="world!"))
+	`)
+
+	// Invoke the starlark interpreter!
+	_, err = starlark.ExecFile(thread, "thefilename.star", script, globals)
+	if err != nil {
+		panic(err)
+	}
+
+	// Output:
+	// ["FooBar"]
+	// struct<FooBar>{
+	// 	foo: string<String>{"helloooo"}
+	// 	bar: string<String>{"world!"}
+	// }
+}
@@ -1,11 +1,13 @@
 module github.com/ipld/go-datalark
 
-go 1.17
+go 1.18
 
 require (
 	github.com/frankban/quicktest v1.14.2
 	github.com/ipfs/go-cid v0.1.0
 	github.com/ipld/go-ipld-prime v0.16.1-0.20220512031633-37f875b8e4c8
+	github.com/multiformats/go-multibase v0.0.3
+	github.com/multiformats/go-multihash v0.1.0
 	github.com/warpfork/go-testmark v0.11.0
 	go.starlark.net v0.0.0-20210901212718-87f333178d59
 )
@@ -20,9 +22,8 @@ require (
 	github.com/mr-tron/base58 v1.2.0 // indirect
 	github.com/multiformats/go-base32 v0.0.4 // indirect
 	github.com/multiformats/go-base36 v0.1.0 // indirect
-	github.com/multiformats/go-multibase v0.0.3 // indirect
-	github.com/multiformats/go-multihash v0.1.0 // indirect
 	github.com/multiformats/go-varint v0.0.6 // indirect
+	github.com/polydawn/refmt v0.0.0-20201211092308-30ac6d18308e // indirect
 	github.com/rogpeppe/go-internal v1.6.1 // indirect
 	github.com/spaolacci/murmur3 v1.1.0 // indirect
 	golang.org/x/crypto v0.0.0-20211117183948-ae814b36b871 // indirect